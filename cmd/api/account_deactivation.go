@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/auth"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// DeactivateAccountPayload confirms the request with the current password or a
+// two-factor code. Both may be left out right after signing in, which is how
+// accounts without a password confirm.
+type DeactivateAccountPayload struct {
+	Password string `json:"password" validate:"omitempty,max=256"`
+	Code     string `json:"code" validate:"omitempty,len=6,numeric"`
+}
+
+var errReauthRequired = errors.New("confirm with your password or a two-factor code, or sign in again")
+
+// deactivateAccountHandler godoc
+//
+//	@Summary		Deactivates the current user
+//	@Description	Hides the profile, posts and comments of the user and signs them out everywhere. Signing in again within the reactivation window brings the account back. Confirm with the current password or a two-factor code, or within a few minutes of signing in.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DeactivateAccountPayload	true	"Confirmation"
+//	@Success		204		{string}	string						"Account deactivated"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/deactivate [post]
+func (app *application) deactivateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DeactivateAccountPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.confirmAccountOwner(r, payload.Password, payload.Code)
+	if err != nil {
+		switch err {
+		case errInvalidCredentials, errReauthRequired:
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Users.DeactivateSelf(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditUserSelfDeactivated,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// confirmAccountOwner makes sure a request that cannot be undone easily comes
+// from the owner of the account and not only from someone holding their token.
+// The owner gives their password or a two-factor code, or has signed in on
+// this session within the reauthentication window. Wrong codes count against
+// the user like wrong second factors at login.
+func (app *application) confirmAccountOwner(r *http.Request, password, code string) (*store.User, error) {
+	ctx := r.Context()
+
+	// the cached user has no password hash, so read it from the database
+	user, err := app.store.Users.GetUserByID(ctx, getUserFromCtx(r).ID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case password != "":
+		if err := user.Password.Compare(password); err != nil {
+			return nil, errInvalidCredentials
+		}
+		return user, nil
+	case code != "":
+		if err := app.checkConfirmationCode(r, user, code); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	recent, err := app.store.Sessions.StartedWithin(ctx, user.ID, getSessionIDFromCtx(r), app.config.account.reauthWindow)
+	if err != nil {
+		return nil, err
+	}
+	if !recent {
+		return nil, errReauthRequired
+	}
+
+	return user, nil
+}
+
+func (app *application) checkConfirmationCode(r *http.Request, user *store.User, code string) error {
+	ctx := r.Context()
+	ip := clientIP(r)
+	subjects := secondFactorSubjects(user.ID, ip)
+
+	wait, err := app.loginRetryAfter(ctx, subjects)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		return errInvalidCredentials
+	}
+
+	tf, err := app.store.TwoFactor.Get(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	if err == nil && tf.Enabled() {
+		step, ok := auth.ValidateTOTP(tf.Secret, code, time.Now())
+		if ok {
+			err = app.store.TwoFactor.UseStep(ctx, user.ID, step)
+			if err != store.ErrNotFound {
+				return err
+			}
+		}
+	}
+
+	if err := app.recordLoginFailure(ctx, subjects, ip, user); err != nil {
+		return err
+	}
+	return errInvalidCredentials
+}
+
+// getSignInUser returns the user signing in with a method that already knows
+// who they are, including a user who deactivated their own account within the
+// reactivation window.
+func (app *application) getSignInUser(ctx context.Context, userID int64) (*store.User, error) {
+	user, err := app.getUser(ctx, userID)
+	if err != store.ErrNotFound {
+		return user, err
+	}
+
+	return app.store.Users.GetDeactivatedByID(ctx, userID, app.config.account.reactivationWindow)
+}
+
+// reactivateAccount brings back the account of a user who deactivated it and
+// has now completed a login. It does nothing when the account is active.
+func (app *application) reactivateAccount(r *http.Request, userID int64) error {
+	ctx := r.Context()
+
+	err := app.store.Users.Reactivate(ctx, userID, app.config.account.reactivationWindow)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	app.invalidateUser(ctx, userID)
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditUserReactivated,
+		ActorID:    userID,
+		TargetType: "user",
+		TargetID:   userID,
+	})
+
+	return nil
+}
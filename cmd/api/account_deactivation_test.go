@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/auth"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// deactivatedUserStore holds one user who deactivated their own account and
+// remembers whether it was reactivated.
+type deactivatedUserStore struct {
+	store.MockUserStore
+	user        *store.User
+	reactivated bool
+}
+
+func (s *deactivatedUserStore) GetUserByID(ctx context.Context, userID int64) (*store.User, error) {
+	return s.user, nil
+}
+
+func (s *deactivatedUserStore) GetByEmail(context.Context, string) (*store.User, error) {
+	return nil, store.ErrNotFound
+}
+
+func (s *deactivatedUserStore) GetDeactivatedByEmail(context.Context, string, time.Duration) (*store.User, error) {
+	return s.user, nil
+}
+
+func (s *deactivatedUserStore) GetDeactivatedByID(context.Context, int64, time.Duration) (*store.User, error) {
+	return s.user, nil
+}
+
+func (s *deactivatedUserStore) Reactivate(context.Context, int64, time.Duration) error {
+	s.reactivated = true
+	return nil
+}
+
+type recentSessionStore struct {
+	store.MockSessionStore
+	recent bool
+}
+
+func (s *recentSessionStore) StartedWithin(context.Context, int64, string, time.Duration) (bool, error) {
+	return s.recent, nil
+}
+
+func newDeactivatedUser(t *testing.T) *store.User {
+	t.Helper()
+
+	user := &store.User{ID: 1, Email: "gopher@example.com"}
+	if err := user.Password.Set("password123"); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestReactivation(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	post := func(path, body string) int {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		return rr.Code
+	}
+
+	t.Run("should reactivate on password login", func(t *testing.T) {
+		users := &deactivatedUserStore{user: newDeactivatedUser(t)}
+		app.store.Users = users
+		app.store.TwoFactor = &store.MockTwoFactorStore{}
+
+		checkResponseCode(t, http.StatusCreated, post("/v1/authentication/token", `{"email":"gopher@example.com","password":"password123"}`))
+
+		if !users.reactivated {
+			t.Error("expected the account to be reactivated")
+		}
+	})
+
+	t.Run("should wait for the second factor before reactivating", func(t *testing.T) {
+		users := &deactivatedUserStore{user: newDeactivatedUser(t)}
+		app.store.Users = users
+		app.store.TwoFactor = &enrolledTwoFactorStore{confirmed: true}
+
+		checkResponseCode(t, http.StatusAccepted, post("/v1/authentication/token", `{"email":"gopher@example.com","password":"password123"}`))
+
+		if users.reactivated {
+			t.Fatal("expected the account to stay deactivated until the second factor")
+		}
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusCreated, post("/v1/authentication/2fa", `{"challenge_token":"t","code":"`+code+`"}`))
+
+		if !users.reactivated {
+			t.Error("expected the account to be reactivated")
+		}
+	})
+
+	t.Run("should not reactivate on a wrong password", func(t *testing.T) {
+		users := &deactivatedUserStore{user: newDeactivatedUser(t)}
+		app.store.Users = users
+		app.store.TwoFactor = &store.MockTwoFactorStore{}
+
+		checkResponseCode(t, http.StatusUnauthorized, post("/v1/authentication/token", `{"email":"gopher@example.com","password":"wrong-password"}`))
+
+		if users.reactivated {
+			t.Error("expected the account to stay deactivated")
+		}
+	})
+}
+
+func TestDeactivateAccount(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	deactivate := func(body string) int {
+		return send(http.MethodPost, "/v1/users/me/deactivate", body).Code
+	}
+
+	app.store.Users = &deactivatedUserStore{user: newDeactivatedUser(t)}
+
+	t.Run("should confirm with the password", func(t *testing.T) {
+		app.store.Sessions = &recentSessionStore{}
+
+		checkResponseCode(t, http.StatusUnauthorized, deactivate(`{"password":"wrong-password"}`))
+		checkResponseCode(t, http.StatusNoContent, deactivate(`{"password":"password123"}`))
+	})
+
+	t.Run("should confirm with a two-factor code", func(t *testing.T) {
+		app.store.Sessions = &recentSessionStore{}
+		app.store.TwoFactor = &enrolledTwoFactorStore{confirmed: true}
+
+		checkResponseCode(t, http.StatusUnauthorized, deactivate(`{"code":"000000"}`))
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusNoContent, deactivate(`{"code":"`+code+`"}`))
+	})
+
+	t.Run("should confirm with a recent sign-in", func(t *testing.T) {
+		app.store.Sessions = &recentSessionStore{}
+		checkResponseCode(t, http.StatusUnauthorized, deactivate(`{}`))
+
+		app.store.Sessions = &recentSessionStore{recent: true}
+		checkResponseCode(t, http.StatusNoContent, deactivate(`{}`))
+	})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/mailer"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// DeleteAccountPayload confirms the request like DeactivateAccountPayload.
+type DeleteAccountPayload struct {
+	Password string `json:"password" validate:"omitempty,max=256"`
+	Code     string `json:"code" validate:"omitempty,len=6,numeric"`
+}
+
+// AccountDeletion tells when a deleted account is purged for good.
+type AccountDeletion struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// deleteAccountHandler godoc
+//
+//	@Summary		Deletes the current user
+//	@Description	Schedules the account for deletion after a grace period, during which the account keeps working and the user can cancel through DELETE /users/me/deletion. Then the account is removed for good along with its posts, comments and followers. Confirm with the current password or a two-factor code, or within a few minutes of signing in.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DeleteAccountPayload	true	"Confirmation"
+//	@Success		202		{object}	AccountDeletion			"Deletion scheduled"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DeleteAccountPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.confirmAccountOwner(r, payload.Password, payload.Code)
+	if err != nil {
+		switch err {
+		case errInvalidCredentials, errReauthRequired:
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	at := time.Now().Add(app.config.account.deletionGrace)
+	if err := app.store.Users.ScheduleDeletion(ctx, user.ID, at); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditUserDeletionScheduled,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Metadata:   map[string]any{"scheduled_at": at},
+	})
+
+	app.background(func() {
+		if err := app.sendDeletionNotice(user, at); err != nil {
+			app.logger.Errorw("error sending account deletion notice", "error", err)
+		}
+	})
+
+	if err := app.writeJsonResponse(w, http.StatusAccepted, &AccountDeletion{DeletionScheduledAt: at}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// cancelAccountDeletionHandler godoc
+//
+//	@Summary		Cancels the deletion of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Success		204	{string}	string	"Deletion cancelled"
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error	"No deletion scheduled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/deletion [delete]
+func (app *application) cancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.store.Users.CancelDeletion(r.Context(), user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditUserDeletionCancelled,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeDeletedAccounts removes the accounts whose grace period is over.
+func (app *application) purgeDeletedAccounts(ctx context.Context) {
+	ids, err := app.store.Users.GetDueDeletions(ctx)
+	if err != nil {
+		app.logger.Errorw("error listing accounts due for deletion", "error", err)
+		return
+	}
+
+	purged := 0
+	for _, id := range ids {
+		keys, err := app.store.Users.Purge(ctx, id)
+		if err != nil {
+			// ErrNotFound: cancelled or purged by another instance meanwhile
+			if err != store.ErrNotFound {
+				app.logger.Errorw("error deleting account", "user_id", id, "error", err)
+			}
+			continue
+		}
+
+		app.deleteBlobs(keys)
+		app.invalidateUser(ctx, id)
+		app.enqueueAudit(&store.AuditEvent{
+			Event:      store.AuditUserDeleted,
+			TargetType: "user",
+			TargetID:   id,
+		})
+		purged++
+	}
+
+	if purged > 0 {
+		app.logger.Infow("deleted accounts", "count", purged)
+	}
+}
+
+func (app *application) sendDeletionNotice(user *store.User, at time.Time) error {
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username     string
+		DeletionDate string
+		SettingsURL  string
+	}{
+		Username:     user.Username,
+		DeletionDate: at.UTC().Format("January 2, 2006"),
+		SettingsURL:  fmt.Sprintf("%s/settings/account", app.config.frontendURL),
+	}
+
+	status, err := app.mailer.Send(mailer.AccountDeletionTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	return nil
+}
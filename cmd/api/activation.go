@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/ecetinerdem/gopherSocial/internal/mailer"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// resendActivationHandler godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Emails a new activation link to an account that has not been activated yet and invalidates the previous one. The response is the same whether or not such an account exists.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendActivationPayload	true	"Account email"
+//	@Success		202		{string}	string					"Activation link sent if the account exists"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Router			/authentication/activation/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// limited per address as well as per email so neither a single client nor
+	// many clients together can flood one inbox
+	for _, key := range []string{"ip:" + clientIP(r), "email:" + loginAccountKey(payload.Email)} {
+		if allow, retryAfter := app.resendLimiter.Allow(key); !allow {
+			app.rateLimitExceedResponse(w, r, retryAfter.String())
+			return
+		}
+	}
+
+	app.background(func() {
+		if err := app.resendActivation(context.Background(), payload.Email); err != nil {
+			app.logger.Errorw("error resending activation", "error", err)
+		}
+	})
+
+	if err := app.writeJsonResponse(w, http.StatusAccepted, "if an inactive account exists for this email, a new activation link has been sent"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) resendActivation(ctx context.Context, email string) error {
+	plainToken, err := generateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	user, err := app.store.Users.Reinvite(ctx, email, hashInvitationToken(plainToken), app.config.mail.exp)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return app.sendActivationEmail(user, plainToken)
+}
+
+func (app *application) sendActivationEmail(user *store.User, plainToken string) error {
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken),
+	}
+
+	status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	return nil
+}
+
+func hashInvitationToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
+}
+
+// sweepInvitations deletes expired activation links and the accounts that were
+// never activated within the grace period.
+func (app *application) sweepInvitations(ctx context.Context) {
+	expired, err := app.store.Users.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		app.logger.Errorw("error deleting expired invitations", "error", err)
+		return
+	}
+
+	pruned, err := app.store.Users.PruneInactive(ctx, app.config.auth.activation.gracePeriod)
+	if err != nil {
+		app.logger.Errorw("error pruning inactive users", "error", err)
+		return
+	}
+
+	if expired > 0 || pruned > 0 {
+		app.logger.Infow("swept invitations", "expired", expired, "pruned_users", pruned)
+	}
+}
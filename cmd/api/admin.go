@@ -0,0 +1,555 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type SetUserRolePayload struct {
+	Role string `json:"role" validate:"required,max=255"`
+}
+
+type DeactivateUserPayload struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type SuspendUserPayload struct {
+	Reason string    `json:"reason" validate:"required,max=500"`
+	Until  time.Time `json:"until" validate:"required"`
+}
+
+type CreateRolePayload struct {
+	Name        string   `json:"name" validate:"required,max=255"`
+	Level       int      `json:"level" validate:"gte=0"`
+	Description string   `json:"description" validate:"max=1000"`
+	Permissions []string `json:"permissions" validate:"dive,max=100"`
+}
+
+type UpdateRolePayload struct {
+	Level       int      `json:"level" validate:"gte=0"`
+	Description string   `json:"description" validate:"max=1000"`
+	Permissions []string `json:"permissions" validate:"dive,max=100"`
+}
+
+// adminListUsersHandler godoc
+//
+//	@Summary		Lists users
+//	@Description	Lists users with their role, optionally filtered by a username or email search, role and active state
+//	@Tags			admin
+//	@Produce		json
+//	@Param			search	query		string	false	"Username or email contains"
+//	@Param			role	query		string	false	"Role name"
+//	@Param			active	query		bool	false	"Active state"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users [get]
+func (app *application) adminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.UserListQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.List(r.Context(), q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// adminSetUserRoleHandler godoc
+//
+//	@Summary		Changes the role of a user
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		SetUserRolePayload	true	"Role name"
+//	@Success		204		{string}	string				"Role changed"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/role [put]
+func (app *application) adminSetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var payload SetUserRolePayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	role, err := app.store.Roles.GetByName(ctx, payload.Role)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestError(w, r, errors.New("unknown role"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	event := stampAudit(r, &store.AuditEvent{
+		Event:      store.AuditUserRoleChanged,
+		ActorID:    getUserFromCtx(r).ID,
+		TargetType: "user",
+		TargetID:   userID,
+		Metadata:   map[string]any{"role": role.Name},
+	})
+
+	if err := app.store.Users.SetRole(ctx, userID, role, event); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminDeactivateUserHandler godoc
+//
+//	@Summary		Deactivates a user
+//	@Description	Locks the user out and revokes all of their sessions
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int						true	"User ID"
+//	@Param			payload	body		DeactivateUserPayload	true	"Reason"
+//	@Success		204		{string}	string					"User deactivated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/deactivate [post]
+func (app *application) adminDeactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var payload DeactivateUserPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	actor := getUserFromCtx(r)
+	if actor.ID == userID {
+		app.badRequestError(w, r, errors.New("admins cannot deactivate themselves"))
+		return
+	}
+
+	ctx := r.Context()
+
+	event := stampAudit(r, &store.AuditEvent{
+		Event:      store.AuditUserDeactivated,
+		ActorID:    actor.ID,
+		TargetType: "user",
+		TargetID:   userID,
+		Metadata:   map[string]any{"reason": payload.Reason},
+	})
+
+	if err := app.store.Users.Deactivate(ctx, userID, event); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminSuspendUserHandler godoc
+//
+//	@Summary		Suspends a user
+//	@Description	Keeps the user out until the given time and revokes all of their sessions. Their profile, posts and comments are hidden meanwhile. Suspending a suspended user replaces the reason and end.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		SuspendUserPayload	true	"Reason and end"
+//	@Success		204		{string}	string				"User suspended"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspension [put]
+func (app *application) adminSuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var payload SuspendUserPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if !payload.Until.After(time.Now()) {
+		app.badRequestError(w, r, errors.New("suspension must end in the future"))
+		return
+	}
+
+	actor := getUserFromCtx(r)
+	if actor.ID == userID {
+		app.badRequestError(w, r, errors.New("moderators cannot suspend themselves"))
+		return
+	}
+
+	ctx := r.Context()
+
+	event := stampAudit(r, &store.AuditEvent{
+		Event:      store.AuditUserSuspended,
+		ActorID:    actor.ID,
+		TargetType: "user",
+		TargetID:   userID,
+		Metadata:   map[string]any{"reason": payload.Reason, "until": payload.Until},
+	})
+
+	if err := app.store.Users.Suspend(ctx, userID, payload.Reason, payload.Until, event); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminUnsuspendUserHandler godoc
+//
+//	@Summary		Lifts the suspension of a user
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Suspension lifted"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"User not suspended"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspension [delete]
+func (app *application) adminUnsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	event := stampAudit(r, &store.AuditEvent{
+		Event:      store.AuditUserUnsuspended,
+		ActorID:    getUserFromCtx(r).ID,
+		TargetType: "user",
+		TargetID:   userID,
+	})
+
+	if err := app.store.Users.Unsuspend(ctx, userID, event); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminListRolesHandler godoc
+//
+//	@Summary		Lists roles
+//	@Description	Lists roles with the permissions granted to them
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	[]store.Role
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles [get]
+func (app *application) adminListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.store.Roles.GetAll(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, roles); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// adminListPermissionsHandler godoc
+//
+//	@Summary		Lists permissions
+//	@Description	Lists the permissions that can be granted to roles
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	[]store.Permission
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles/permissions [get]
+func (app *application) adminListPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	permissions, err := app.store.Roles.GetPermissions(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, permissions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// adminCreateRoleHandler godoc
+//
+//	@Summary		Creates a role
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateRolePayload	true	"Role"
+//	@Success		201		{object}	store.Role
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles [post]
+func (app *application) adminCreateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateRolePayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	role := &store.Role{
+		Name:        payload.Name,
+		Level:       payload.Level,
+		Description: payload.Description,
+		Permissions: payload.Permissions,
+	}
+
+	event := stampAudit(r, &store.AuditEvent{
+		Event:   store.AuditRoleCreated,
+		ActorID: getUserFromCtx(r).ID,
+	})
+
+	if err := app.store.Roles.Create(r.Context(), role, event); err != nil {
+		switch err {
+		case store.ErrDataConflict:
+			app.conflictError(w, r, err)
+		case store.ErrUnknownPermission:
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusCreated, role); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// adminUpdateRoleHandler godoc
+//
+//	@Summary		Updates a role
+//	@Description	Replaces the level, description and permissions of a role
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			roleID	path		int					true	"Role ID"
+//	@Param			payload	body		UpdateRolePayload	true	"Role"
+//	@Success		200		{object}	store.Role
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/roles/{roleID} [put]
+func (app *application) adminUpdateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.ParseInt(chi.URLParam(r, "roleID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	var payload UpdateRolePayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	role := &store.Role{
+		ID:          roleID,
+		Level:       payload.Level,
+		Description: payload.Description,
+		Permissions: payload.Permissions,
+	}
+
+	event := stampAudit(r, &store.AuditEvent{
+		Event:   store.AuditRoleUpdated,
+		ActorID: getUserFromCtx(r).ID,
+	})
+
+	if err := app.store.Roles.Update(r.Context(), role, event); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		case store.ErrUnknownPermission:
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, role); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// adminListChangesHandler godoc
+//
+//	@Summary		Lists admin changes
+//	@Description	Lists who changed which user or role through the admin API, newest first. These are the admin events of the audit log.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			target_type	query		string	false	"user or role"
+//	@Param			target_id	query		int		false	"Target ID"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{object}	[]store.AuditEvent
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/changes [get]
+func (app *application) adminListChangesHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.AdminChangeQuery{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	changes, err := app.store.AuditEvents.GetAll(r.Context(), store.AuditEventQuery{
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+		TargetType: q.TargetType,
+		TargetID:   q.TargetID,
+		Events:     store.AdminChangeEvents,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, changes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
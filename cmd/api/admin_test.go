@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	_, send := newSignedInTestApplication(t, config{})
+
+	t.Run("should forbid users without the permission", func(t *testing.T) {
+		for _, path := range []string{"/v1/admin/users", "/v1/admin/roles", "/v1/admin/changes"} {
+			checkResponseCode(t, http.StatusForbidden, send(http.MethodGet, path, "").Code)
+		}
+	})
+
+	t.Run("should forbid users without the permission to suspend", func(t *testing.T) {
+		for _, method := range []string{http.MethodPut, http.MethodDelete} {
+			checkResponseCode(t, http.StatusForbidden, send(method, "/v1/admin/users/7/suspension", "").Code)
+		}
+	})
+}
+
+// adminUserStore gives every user the role the mock store grants all
+// permissions to, and keeps the audit event of the last role change.
+type adminUserStore struct {
+	store.MockUserStore
+	event *store.AuditEvent
+}
+
+func (s *adminUserStore) GetUserByID(ctx context.Context, userID int64) (*store.User, error) {
+	return &store.User{ID: userID, Role: store.Role{ID: 3}}, nil
+}
+
+func (s *adminUserStore) SetRole(ctx context.Context, userID int64, role *store.Role, event *store.AuditEvent) error {
+	s.event = event
+	return nil
+}
+
+type queryingAuditStore struct {
+	store.MockAuditEventStore
+	query store.AuditEventQuery
+}
+
+func (s *queryingAuditStore) GetAll(ctx context.Context, q store.AuditEventQuery) ([]*store.AuditEvent, error) {
+	s.query = q
+	return []*store.AuditEvent{}, nil
+}
+
+func TestAdminChanges(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	users := &adminUserStore{}
+	events := &queryingAuditStore{}
+	app.store.Users = users
+	app.store.AuditEvents = events
+	app.auditEvents = make(chan *store.AuditEvent, 1)
+
+	t.Run("should record a change once, along with it", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/admin/users/7/role", `{"role":"moderator"}`).Code)
+
+		e := users.event
+		if e == nil || e.Event != store.AuditUserRoleChanged || e.ActorID != 1 || e.TargetID != 7 || e.RequestID == "" {
+			t.Errorf("expected the store to record the role change of user 7 but got %+v", e)
+		}
+		if len(app.auditEvents) != 0 {
+			t.Error("expected the change not to be queued for the audit log as well")
+		}
+	})
+
+	t.Run("should list the admin events of the audit log", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/admin/changes?target_type=user&target_id=7", "").Code)
+
+		q := events.query
+		if !slices.Equal(q.Events, store.AdminChangeEvents) || q.TargetType != "user" || q.TargetID != 7 {
+			t.Errorf("expected the admin events of user 7 but got %+v", q)
+		}
+	})
+}
@@ -8,13 +8,16 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/ecetinerdem/gopherSocial/docs"
 	"github.com/ecetinerdem/gopherSocial/internal/auth"
+	"github.com/ecetinerdem/gopherSocial/internal/blob"
 	"github.com/ecetinerdem/gopherSocial/internal/env"
 	"github.com/ecetinerdem/gopherSocial/internal/mailer"
+	"github.com/ecetinerdem/gopherSocial/internal/oidc"
 	"github.com/ecetinerdem/gopherSocial/internal/ratelimiter"
 	"github.com/ecetinerdem/gopherSocial/internal/store"
 	"github.com/ecetinerdem/gopherSocial/internal/store/cache"
@@ -33,6 +36,16 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	resendLimiter ratelimiter.Limiter
+	magicLimiter  ratelimiter.Limiter
+	auditEvents   chan *store.AuditEvent
+	blobs         blob.Store
+	exportBlobs   blob.Store
+	// imageSlots holds a token for every upload being decoded, since each
+	// keeps all its pixels in memory
+	imageSlots    chan struct{}
+	oidcProviders map[string]*oidc.Provider
+	wg            sync.WaitGroup
 }
 
 type config struct {
@@ -45,6 +58,59 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	oidc        []oidc.Config
+	audit       auditConfig
+	media       mediaConfig
+	account     accountConfig
+	suggestions suggestionsConfig
+	publisher   publisherConfig
+}
+
+type publisherConfig struct {
+	interval  time.Duration
+	batchSize int
+}
+
+type suggestionsConfig struct {
+	maxAge        time.Duration
+	batchSize     int
+	batchInterval time.Duration
+}
+
+type accountConfig struct {
+	deletionGrace      time.Duration
+	sweepInterval      time.Duration
+	exportTTL          time.Duration
+	exportPollInterval time.Duration
+	exportStaleAfter   time.Duration
+	reactivationWindow time.Duration
+	reauthWindow       time.Duration
+}
+
+type auditConfig struct {
+	queueSize     int
+	batchSize     int
+	flushInterval time.Duration
+	retention     time.Duration
+	sweepInterval time.Duration
+}
+
+type mediaConfig struct {
+	backend        string
+	maxUploadBytes int64
+	maxPixels      int
+	maxConcurrent  int
+	local          localMediaConfig
+	s3             blob.S3Config
+	// exports are kept apart from the public media, in a directory that is
+	// not served or a private bucket
+	exportsDir    string
+	exportsBucket string
+}
+
+type localMediaConfig struct {
+	dir     string
+	baseURL string
 }
 
 type redisConfig struct {
@@ -55,8 +121,14 @@ type redisConfig struct {
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic      basicConfig
+	token      tokenConfig
+	twoFactor  twoFactorConfig
+	lockout    lockoutConfig
+	activation activationConfig
+	sessions   sessionConfig
+	password   passwordConfig
+	magicLink  magicLinkConfig
 }
 
 type basicConfig struct {
@@ -65,14 +137,59 @@ type basicConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	expiry time.Duration
-	iss    string
+	alg           string
+	keyFiles      []string
+	secret        string
+	expiry        time.Duration
+	refreshExpiry time.Duration
+	iss           string
 }
+
+type twoFactorConfig struct {
+	issuer       string
+	challengeExp time.Duration
+}
+
+type lockoutConfig struct {
+	freeAttempts     int
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+	accountThreshold int
+	ipThreshold      int
+	duration         time.Duration
+}
+
+type passwordConfig struct {
+	memory      int
+	iterations  int
+	parallelism int
+}
+
+type activationConfig struct {
+	resendLimit   int
+	resendWindow  time.Duration
+	gracePeriod   time.Duration
+	sweepInterval time.Duration
+}
+
+type sessionConfig struct {
+	// ended sessions are kept this long before they are deleted
+	retention     time.Duration
+	sweepInterval time.Duration
+}
+
+type magicLinkConfig struct {
+	exp         time.Duration
+	limit       int
+	limitWindow time.Duration
+}
+
 type mailConfig struct {
-	sendGrid  sendGridConfig
-	exp       time.Duration
-	fromEmail string
+	sendGrid       sendGridConfig
+	exp            time.Duration
+	resetExp       time.Duration
+	emailChangeExp time.Duration
+	fromEmail      string
 }
 
 type sendGridConfig struct {
@@ -98,7 +215,7 @@ func (app *application) mount() *chi.Mux {
 		// AllowedOrigins:   []string{"https://foo.com"}, // Use this to allow specific origin hosts
 		AllowedOrigins: []string{env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:5174")},
 		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
@@ -111,6 +228,12 @@ func (app *application) mount() *chi.Mux {
 	// processing should be stopped.
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
+	if local, ok := app.blobs.(*blob.LocalStore); ok {
+		r.Handle("/media/*", http.StripPrefix("/media/", local.Handler()))
+	}
+
 	r.Route("/v1", func(r chi.Router) {
 		r.With(app.BasicAuth()).Get("/healthz", app.healthCheckHandler)
 		r.With(app.BasicAuth()).Get("/debug/vars", expvar.Handler().ServeHTTP)
@@ -122,14 +245,15 @@ func (app *application) mount() *chi.Mux {
 
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleWare)
-			r.Post("/", app.createPostHandler)
+			r.With(app.RequireScope(scopePostsWrite)).Post("/", app.createPostHandler)
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postsContextMiddleWare)
 
-				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Post("/comments", app.createCommentHandler)
+				r.With(app.RequireScope(scopePostsRead)).Get("/", app.getPostHandler)
+				r.With(app.RequireScope(scopePostsWrite), app.Authorize(app.ownerOr(postOwner, permPostDeleteAny))).Delete("/", app.deletePostHandler)
+				r.With(app.RequireScope(scopePostsWrite), app.Authorize(app.ownerOr(postOwner, permPostUpdateAny))).Patch("/", app.updatePostHandler)
+				r.With(app.RequireScope(scopeCommentsWrite)).Post("/comments", app.createCommentHandler)
+				r.With(app.RequireScope(scopeCommentsWrite), app.RequirePermission(permCommentHide)).Delete("/comments/{commentID}", app.hideCommentHandler)
 			})
 		})
 		r.Route("/users", func(r chi.Router) {
@@ -137,20 +261,110 @@ func (app *application) mount() *chi.Mux {
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleWare)
 
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/", app.getUserHandler)
+				r.With(app.RequireScope(scopeUsersWrite)).Put("/follow", app.followUserHandler)
+				r.With(app.RequireScope(scopeUsersWrite)).Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/followers", app.getFollowersHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/following", app.getFollowingHandler)
+				r.With(app.RequireScope(scopeUsersWrite)).Put("/block", app.blockUserHandler)
+				r.With(app.RequireScope(scopeUsersWrite)).Put("/unblock", app.unblockUserHandler)
+				r.With(app.RequireScope(scopeUsersWrite)).Put("/mute", app.muteUserHandler)
+				r.With(app.RequireScope(scopeUsersWrite)).Put("/unmute", app.unmuteUserHandler)
 			})
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleWare)
-				r.Get("/feed", app.getUserFeedHandler)
+				r.With(app.RequireScope(scopeFeedRead)).Get("/feed", app.getUserFeedHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/search", app.searchUsersHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/autocomplete", app.autocompleteUsersHandler)
+			})
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleWare)
+				r.Use(app.RequireScope(scopeAccount))
+
+				r.Patch("/", app.updateProfileHandler)
+				r.Delete("/", app.deleteAccountHandler)
+				r.Delete("/deletion", app.cancelAccountDeletionHandler)
+				r.Post("/deactivate", app.deactivateAccountHandler)
+				r.Post("/export", app.requestExportHandler)
+				r.Get("/export", app.listExportsHandler)
+				r.Get("/export/{exportID}", app.downloadExportHandler)
+				r.Get("/suggestions", app.getSuggestionsHandler)
+				r.With(app.RequireScope(scopePostsRead)).Get("/drafts", app.listDraftsHandler)
+				r.Put("/avatar", app.putImageHandler(store.UserImageAvatar))
+				r.Delete("/avatar", app.deleteImageHandler(store.UserImageAvatar))
+				r.Put("/banner", app.putImageHandler(store.UserImageBanner))
+				r.Delete("/banner", app.deleteImageHandler(store.UserImageBanner))
+
+				r.Post("/2fa", app.enrollTwoFactorHandler)
+				r.Post("/2fa/confirm", app.confirmTwoFactorHandler)
+
+				r.Post("/identities/{provider}", app.linkIdentityHandler)
+
+				r.Get("/api-keys", app.listAPIKeysHandler)
+				r.Post("/api-keys", app.createAPIKeyHandler)
+				r.Delete("/api-keys/{keyID}", app.revokeAPIKeyHandler)
+
+				r.Post("/email", app.changeEmailHandler)
+				r.Put("/email/confirm/{token}", app.confirmEmailChangeHandler)
+
+				r.Get("/sessions", app.listSessionsHandler)
+				r.Delete("/sessions", app.revokeOtherSessionsHandler)
+				r.Delete("/sessions/{sessionID}", app.revokeSessionHandler)
+
+				r.Delete("/magic-links", app.revokeMagicLinksHandler)
+
+				r.Get("/follow-requests", app.listFollowRequestsHandler)
+				r.Put("/follow-requests/{userID}/approve", app.approveFollowRequestHandler)
+				r.Delete("/follow-requests/{userID}", app.rejectFollowRequestHandler)
+
+				r.Get("/blocks", app.listBlockedHandler)
+				r.Get("/mutes", app.listMutedHandler)
+			})
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleWare)
+			r.Use(app.RequireScope(scopeAccount))
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequirePermission(permUserManage))
+
+				r.Get("/users", app.adminListUsersHandler)
+				r.Put("/users/{userID}/role", app.adminSetUserRoleHandler)
+				r.Post("/users/{userID}/deactivate", app.adminDeactivateUserHandler)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequirePermission(permUserBan))
+
+				r.Put("/users/{userID}/suspension", app.adminSuspendUserHandler)
+				r.Delete("/users/{userID}/suspension", app.adminUnsuspendUserHandler)
 			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequirePermission(permRoleManage))
+
+				r.Get("/roles", app.adminListRolesHandler)
+				r.Post("/roles", app.adminCreateRoleHandler)
+				r.Get("/roles/permissions", app.adminListPermissionsHandler)
+				r.Put("/roles/{roleID}", app.adminUpdateRoleHandler)
+				r.Get("/changes", app.adminListChangesHandler)
+			})
+			r.With(app.RequirePermission(permAuditRead)).Get("/audit", app.listAuditEventsHandler)
 		})
 
 		//Public routes
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/2fa", app.verifyTwoFactorHandler)
+			r.With(app.AuthTokenMiddleWare, app.RequireScope(scopeAccount)).Post("/logout", app.logoutHandler)
+			r.Post("/activation/resend", app.resendActivationHandler)
+			r.Post("/magic-link", app.requestMagicLinkHandler)
+			r.Post("/magic-link/exchange", app.exchangeMagicLinkHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
+			r.Get("/oidc/{provider}/login", app.oidcLoginHandler)
+			r.Get("/oidc/{provider}/callback", app.oidcCallbackHandler)
 		})
 	})
 
@@ -170,6 +384,21 @@ func (app *application) run(mux *chi.Mux) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	app.periodic(jobs, "invitation sweeper", app.config.auth.activation.sweepInterval, app.sweepInvitations)
+	app.periodic(jobs, "session sweeper", app.config.auth.sessions.sweepInterval, app.sweepSessions)
+	app.periodic(jobs, "audit retention", app.config.audit.sweepInterval, app.pruneAuditEvents)
+	app.periodic(jobs, "account deletion", app.config.account.sweepInterval, app.purgeDeletedAccounts)
+	app.periodic(jobs, "data exports", app.config.account.exportPollInterval, app.processExports)
+	app.periodic(jobs, "data export retention", app.config.account.sweepInterval, app.pruneExports)
+	app.periodic(jobs, "follow suggestions", app.config.suggestions.batchInterval, app.refreshSuggestions)
+	app.periodic(jobs, "scheduled posts", app.config.publisher.interval, app.publishScheduledPosts)
+	app.background(func() {
+		app.writeAuditEvents(jobs)
+	})
+
 	shutdown := make(chan error)
 
 	go func() {
@@ -183,7 +412,16 @@ func (app *application) run(mux *chi.Mux) error {
 		defer cancel()
 
 		app.logger.Infow("signal caught", "signal", s.String())
-		shutdown <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdown <- err
+			return
+		}
+
+		app.logger.Infow("completing background tasks", "addr", app.config.addr)
+		stopJobs()
+		app.wg.Wait()
+		shutdown <- nil
 
 	}()
 
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// apiKeyPrefix marks a bearer credential as a personal API key rather than a JWT.
+const apiKeyPrefix = "gsk_"
+
+// Scopes a route can require. Access tokens from a login carry every scope,
+// API keys only the ones they were created with.
+const (
+	scopePostsRead     = "posts:read"
+	scopePostsWrite    = "posts:write"
+	scopeCommentsWrite = "comments:write"
+	scopeUsersRead     = "users:read"
+	scopeUsersWrite    = "users:write"
+	scopeFeedRead      = "feed:read"
+
+	// scopeAccount guards credential and account management. It cannot be
+	// granted to an API key, so a leaked key can't mint new credentials.
+	scopeAccount = "account"
+)
+
+type CreateAPIKeyPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write comments:write users:read users:write feed:read"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"gte=0,lte=365"`
+}
+
+type APIKeyWithToken struct {
+	*store.APIKey
+	Token string `json:"token"`
+}
+
+type apiKeyKey string
+
+const apiKeyCtx apiKeyKey = "apiKey"
+
+// createAPIKeyHandler godoc
+//
+//	@Summary		Creates an API key
+//	@Description	Creates a personal API key restricted to the given scopes. The token is only returned once.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAPIKeyPayload	true	"API key"
+//	@Success		201		{object}	APIKeyWithToken
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys [post]
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAPIKeyPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	secret, err := generateRandomToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	token := apiKeyPrefix + secret
+
+	user := getUserFromCtx(r)
+	key := &store.APIKey{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Prefix: token[:len(apiKeyPrefix)+8],
+		Scopes: payload.Scopes,
+	}
+
+	if payload.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, payload.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := app.store.APIKeys.Create(r.Context(), key, token); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditAPIKeyCreated,
+		ActorID:    key.UserID,
+		TargetType: "user",
+		TargetID:   key.UserID,
+		Metadata:   map[string]any{"key_id": key.ID, "scopes": key.Scopes},
+	})
+
+	if err := app.writeJsonResponse(w, http.StatusCreated, &APIKeyWithToken{APIKey: key, Token: token}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// listAPIKeysHandler godoc
+//
+//	@Summary		Lists API keys
+//	@Description	Lists the active personal API keys of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.APIKey
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys [get]
+func (app *application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	keys, err := app.store.APIKeys.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, keys); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// revokeAPIKeyHandler godoc
+//
+//	@Summary		Revokes an API key
+//	@Description	Revokes a personal API key by ID
+//	@Tags			users
+//	@Produce		json
+//	@Param			keyID	path		int		true	"API key ID"
+//	@Success		204		{string}	string	"API key revoked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/api-keys/{keyID} [delete]
+func (app *application) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keyID, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	err = app.store.APIKeys.Revoke(r.Context(), user.ID, keyID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiKeyAuth authenticates a request made with a personal API key.
+func (app *application) apiKeyAuth(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	ctx := r.Context()
+
+	key, err := app.store.APIKeys.Authenticate(ctx, token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.getUser(ctx, key.UserID)
+	if err != nil {
+		app.unAuthorizedError(w, r, err)
+		return
+	}
+
+	if user.Suspended() {
+		app.suspendedError(w, r, user.Suspension)
+		return
+	}
+
+	ctx = context.WithValue(ctx, userCtx, user)
+	ctx = context.WithValue(ctx, apiKeyCtx, key)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func getAPIKeyFromCtx(r *http.Request) *store.APIKey {
+	key, _ := r.Context().Value(apiKeyCtx).(*store.APIKey)
+
+	return key
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// audit queues event for the audit log, stamped with the address and request
+// ID of r. It never blocks: when the queue is full the event is logged and
+// dropped so a slow database cannot hold up the request.
+func (app *application) audit(r *http.Request, event *store.AuditEvent) {
+	app.enqueueAudit(stampAudit(r, event))
+}
+
+// stampAudit stamps event with the address and request ID of r. Admin changes
+// are stamped this way and handed to the store, which records them along with
+// the change instead of through the queue.
+func stampAudit(r *http.Request, event *store.AuditEvent) *store.AuditEvent {
+	event.IP = clientIP(r)
+	event.RequestID = middleware.GetReqID(r.Context())
+
+	return event
+}
+
+// enqueueAudit queues an event that no request caused, such as the work of a
+// background job.
+func (app *application) enqueueAudit(event *store.AuditEvent) {
+	event.CreatedAt = time.Now()
+
+	select {
+	case app.auditEvents <- event:
+	default:
+		app.logger.Warnw("audit queue full, event dropped",
+			"event", event.Event,
+			"actor_id", event.ActorID,
+			"target_type", event.TargetType,
+			"target_id", event.TargetID,
+			"ip", event.IP,
+			"request_id", event.RequestID,
+		)
+	}
+}
+
+// writeAuditEvents drains the audit queue into the store in batches until ctx
+// is cancelled, then writes whatever is still queued.
+func (app *application) writeAuditEvents(ctx context.Context) {
+	cfg := app.config.audit
+	batch := make([]*store.AuditEvent, 0, cfg.batchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		// the request contexts are long gone, so writes get their own
+		writeCtx, cancel := context.WithTimeout(context.Background(), store.QueryTimeOutDuration)
+		defer cancel()
+
+		if err := app.store.AuditEvents.Create(writeCtx, batch); err != nil {
+			app.logger.Errorw("error writing audit events", "count", len(batch), "error", err)
+			for _, e := range batch {
+				app.logger.Warnw("audit event lost", "event", e.Event, "actor_id", e.ActorID, "target_id", e.TargetID, "request_id", e.RequestID)
+			}
+		}
+
+		batch = batch[:0]
+	}
+
+	ticker := time.NewTicker(cfg.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-app.auditEvents:
+			batch = append(batch, e)
+			if len(batch) >= cfg.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case e := <-app.auditEvents:
+					batch = append(batch, e)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// pruneAuditEvents enforces the retention period of the audit log.
+func (app *application) pruneAuditEvents(ctx context.Context) {
+	deleted, err := app.store.AuditEvents.DeleteBefore(ctx, time.Now().Add(-app.config.audit.retention))
+	if err != nil {
+		app.logger.Errorw("error pruning audit events", "error", err)
+		return
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("pruned audit events", "deleted", deleted)
+	}
+}
+
+// listAuditEventsHandler godoc
+//
+//	@Summary		Lists audit events
+//	@Description	Queries the security and admin audit log, newest first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			event		query		string	false	"Event type"
+//	@Param			actor_id	query		int		false	"Actor ID"
+//	@Param			target_type	query		string	false	"user, post, role or session"
+//	@Param			target_id	query		int		false	"Target ID"
+//	@Param			ip			query		string	false	"Client IP"
+//	@Param			request_id	query		string	false	"Request ID"
+//	@Param			since		query		string	false	"RFC 3339 time, inclusive"
+//	@Param			until		query		string	false	"RFC 3339 time, exclusive"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{object}	[]store.AuditEvent
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/audit [get]
+func (app *application) listAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.AuditEventQuery{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	events, err := app.store.AuditEvents.GetAll(r.Context(), q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, events); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+type recordingAuditStore struct {
+	store.MockAuditEventStore
+	mu     sync.Mutex
+	events []*store.AuditEvent
+}
+
+func (s *recordingAuditStore) Create(ctx context.Context, events []*store.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	app := newTestApplication(t, config{
+		audit: auditConfig{batchSize: 10, flushInterval: time.Hour},
+	})
+	recorder := &recordingAuditStore{}
+	app.store.AuditEvents = recorder
+
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "203.0.113.7:4242"
+
+	t.Run("should not block when the queue is full", func(t *testing.T) {
+		app.auditEvents = make(chan *store.AuditEvent, 1)
+
+		done := make(chan struct{})
+		go func() {
+			app.audit(req, &store.AuditEvent{Event: store.AuditLoginFailed})
+			app.audit(req, &store.AuditEvent{Event: store.AuditLoginFailed})
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("audit blocked on a full queue")
+		}
+	})
+
+	t.Run("should write queued events on shutdown", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		app.writeAuditEvents(ctx)
+
+		if len(recorder.events) != 1 {
+			t.Fatalf("expected 1 event to be written but got %d", len(recorder.events))
+		}
+
+		if ip := recorder.events[0].IP; ip != "203.0.113.7" {
+			t.Errorf("expected the client IP to be recorded but got %q", ip)
+		}
+	})
+}
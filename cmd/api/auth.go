@@ -1,22 +1,24 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"fmt"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/ecetinerdem/gopherSocial/internal/mailer"
 	"github.com/ecetinerdem/gopherSocial/internal/store"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 type RegisterUserPayload struct {
-	Username string `json:"username", validate:"required,max=100"`
-	Email    string `json:"email", validate:"required,email,max=255	"`
-	Password string `json:"password", validate:"required,min=3,max=72"`
+	Username string `json:"username" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=3,max=256"`
 }
 
 type UserWithToken struct {
@@ -69,8 +71,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	plainToken := uuid.New().String()
-	hash := sha256.Sum256([]byte(plainToken))
-	hashedToken := hex.EncodeToString(hash[:])
+	hashedToken := hashInvitationToken(plainToken)
 
 	// store user TODO: Randomize the token
 	err = app.store.Users.CreateAndInvite(r.Context(), user, hashedToken, app.config.mail.exp)
@@ -92,17 +93,8 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Token: plainToken,
 	}
 
-	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
-	isProdEnv := app.config.env == "production"
-	vars := struct {
-		Username      string
-		ActivationURL string
-	}{
-		Username:      user.Username,
-		ActivationURL: activationURL,
-	}
 	// send email
-	status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	err = app.sendActivationEmail(user, plainToken)
 	if err != nil {
 		app.logger.Errorw("error sending welcome email", "error", err)
 		if err := app.store.Users.Delete(r.Context(), user.ID); err != nil {
@@ -113,8 +105,6 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	app.logger.Infow("Email sent", "status code", status)
-
 	err = app.writeJsonResponse(w, http.StatusCreated, userWithToken)
 
 	if err != nil {
@@ -124,22 +114,27 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 }
 
+var errInvalidCredentials = errors.New("invalid credentials")
+
 type CreateUserTokenPayload struct {
-	Email    string `json:"email", validate:"required,email,max=255	"`
-	Password string `json:"password", validate:"required,min=3,max=72"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=3,max=256"`
 }
 
 // createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Creates a token for a user. Signing in reactivates an account its owner deactivated, within the reactivation window.
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{object}	AuthTokens				"Tokens"
+//	@Success		202		{object}	TwoFactorChallenge		"Two-factor code required"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error	"Account suspended"
+//	@Failure		429		{object}	error	"Too many failed attempts"
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -159,10 +154,169 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	ctx := r.Context()
+	ip := clientIP(r)
+
+	retryAfter, err := app.loginRetryAfter(ctx, loginSubjects(payload.Email, ip))
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if retryAfter > 0 {
+		app.audit(r, &store.AuditEvent{
+			Event:    store.AuditLoginFailed,
+			Metadata: map[string]any{"email": payload.Email, "reason": "throttled"},
+		})
+		app.rateLimitExceedResponse(w, r, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+
+	if err == store.ErrNotFound {
+		// users who deactivated their own account get it back by signing in
+		user, err = app.store.Users.GetDeactivatedByEmail(ctx, payload.Email, app.config.account.reactivationWindow)
+	}
+
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	valid := false
+	if err == store.ErrNotFound {
+		user = nil
+		// keeps unknown emails as slow to reject as wrong passwords
+		dummyUser().Password.Compare(payload.Password)
+	} else {
+		valid = user.Password.Compare(payload.Password) == nil
+	}
+
+	if !valid {
+		failure := &store.AuditEvent{
+			Event:    store.AuditLoginFailed,
+			Metadata: map[string]any{"email": payload.Email, "reason": "invalid_credentials"},
+		}
+		if user != nil {
+			failure.TargetType = "user"
+			failure.TargetID = user.ID
+		}
+		app.audit(r, failure)
+
+		if err := app.recordLoginFailure(ctx, loginSubjects(payload.Email, ip), ip, user); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		app.unAuthorizedError(w, r, errInvalidCredentials)
+		return
+	}
+
+	if user.Suspended() {
+		app.audit(r, &store.AuditEvent{
+			Event:      store.AuditLoginSuspended,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Metadata:   map[string]any{"email": payload.Email},
+		})
+		app.suspendedError(w, r, user.Suspension)
+		return
+	}
+
+	if user.Password.NeedsRehash() {
+		app.rehashPassword(ctx, user, payload.Password)
+	}
+
+	challenge, err := app.twoFactorChallenge(r.Context(), user.ID)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the counters are only cleared once the second factor checks out too
+	if challenge != nil {
+		if err := app.writeJsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.resetLoginFailures(ctx, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.createSession(r, user.ID, "password")
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.writeJsonResponse(w, http.StatusCreated, tokens)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+type AuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access and refresh token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	AuthTokens			"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
 
+	refreshToken, err := generateRandomToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	next := &store.RefreshToken{
+		Expiry: time.Now().Add(app.config.auth.token.refreshExpiry),
+	}
+
+	err = app.store.RefreshTokens.Rotate(ctx, payload.RefreshToken, refreshToken, next)
 	if err != nil {
 		switch err {
+		case store.ErrTokenReused:
+			app.logger.Warnw("refresh token reuse detected, session revoked", "method", r.Method, "path", r.URL.Path)
+			app.audit(r, &store.AuditEvent{Event: store.AuditTokenReused})
+			app.unAuthorizedError(w, r, err)
 		case store.ErrNotFound:
 			app.unAuthorizedError(w, r, err)
 		default:
@@ -171,30 +325,162 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := user.Password.Compare(payload.Password); err != nil {
+	user, err := app.getUser(ctx, next.UserID)
+	if err != nil {
 		app.unAuthorizedError(w, r, err)
 		return
 	}
 
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.expiry).Unix(),
-		"iat": time.Now().Unix(),
-		"iss": app.config.auth.token.iss,
-		"aud": app.config.auth.token.iss,
+	if user.Suspended() {
+		app.suspendedError(w, r, user.Suspension)
+		return
 	}
 
-	token, err := app.authenticator.GenerateToken(claims)
-
+	accessToken, err := app.generateAccessToken(next.UserID, next.SessionID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	err = app.writeJsonResponse(w, http.StatusCreated, token)
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditTokenRefreshed,
+		ActorID:    next.UserID,
+		TargetType: "user",
+		TargetID:   next.UserID,
+		Metadata:   map[string]any{"session_id": next.SessionID},
+	})
+
+	tokens := &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(app.config.auth.token.expiry.Seconds()),
+	}
 
-	if err != nil {
+	if err := app.writeJsonResponse(w, http.StatusCreated, tokens); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the session of the current access token and its refresh tokens
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		204	{string}	string	"Logged out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	sessionID := getSessionIDFromCtx(r)
+
+	err := app.store.Sessions.Revoke(r.Context(), user.ID, sessionID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rehashPassword upgrades the stored hash of a password that was just verified
+// to the current algorithm and parameters. Failing to do so doesn't fail the
+// login; it is tried again the next time.
+func (app *application) rehashPassword(ctx context.Context, user *store.User, plain string) {
+	if err := user.Password.Set(plain); err != nil {
+		app.logger.Errorw("error rehashing password", "user_id", user.ID, "error", err)
+		return
+	}
+
+	if err := app.store.Users.UpdatePassword(ctx, user); err != nil {
+		app.logger.Errorw("error rehashing password", "user_id", user.ID, "error", err)
+		return
+	}
+
+	app.logger.Infow("password rehashed", "user_id", user.ID)
+}
+
+// createSession starts a new session for the user on the device making the
+// request and returns its first access and refresh token pair. method names
+// how the user signed in for the audit log. It is only called once the login
+// is complete, so this is also where a deactivated account comes back.
+func (app *application) createSession(r *http.Request, userID int64, method string) (*AuthTokens, error) {
+	if err := app.reactivateAccount(r, userID); err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &store.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		UserAgent: userAgent(r),
+		IP:        clientIP(r),
+	}
+
+	rt := &store.RefreshToken{
+		Expiry: time.Now().Add(app.config.auth.token.refreshExpiry),
+	}
+
+	if err := app.store.Sessions.Create(r.Context(), session, refreshToken, rt); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.generateAccessToken(userID, rt.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditLoginSucceeded,
+		ActorID:    userID,
+		TargetType: "user",
+		TargetID:   userID,
+		Metadata:   map[string]any{"method": method, "session_id": session.ID},
+	})
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(app.config.auth.token.expiry.Seconds()),
+	}, nil
+}
+
+func (app *application) generateAccessToken(userID int64, sessionID string) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"sid": sessionID,
+		"exp": time.Now().Add(app.config.auth.token.expiry).Unix(),
+		"iat": time.Now().Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+func generateRandomToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type sessionKey string
+
+const sessionCtx sessionKey = "session"
+
+func getSessionIDFromCtx(r *http.Request) string {
+	sessionID, _ := r.Context().Value(sessionCtx).(string)
+
+	return sessionID
+}
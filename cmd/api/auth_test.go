@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/ratelimiter"
+)
+
+func TestLogout(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow unauthenticated requests", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should revoke the current session", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should reject a missing refresh token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should issue a new token pair", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{"refresh_token":"abc"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+	})
+}
+
+func TestRevokeSession(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should reject malformed session IDs", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me/sessions/not-a-uuid", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should revoke a session by ID", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me/sessions/0b9a1e2c-3f4d-4e5f-8a6b-7c8d9e0f1a2b", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+func TestLoginDelay(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{
+			lockout: lockoutConfig{
+				freeAttempts: 3,
+				baseDelay:    time.Second,
+				maxDelay:     time.Second * 5,
+			},
+		},
+	})
+
+	tests := map[int]time.Duration{
+		1: 0,
+		3: 0,
+		4: time.Second,
+		5: time.Second * 2,
+		6: time.Second * 4,
+		7: time.Second * 5,
+		9: time.Second * 5,
+	}
+
+	for failures, expected := range tests {
+		if delay := app.loginDelay(failures); delay != expected {
+			t.Errorf("expected a delay of %s after %d failures but got %s", expected, failures, delay)
+		}
+	}
+}
+
+func TestResendActivation(t *testing.T) {
+	app := newTestApplication(t, config{})
+	app.resendLimiter = ratelimiter.NewFixedWindowLimiter(1, time.Minute)
+	mux := app.mount()
+
+	send := func() int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/activation/resend", strings.NewReader(`{"email":"gopher@example.com"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		return rr.Code
+	}
+
+	t.Run("should accept the first request", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, send())
+	})
+
+	t.Run("should rate limit repeated requests", func(t *testing.T) {
+		checkResponseCode(t, http.StatusTooManyRequests, send())
+	})
+
+	app.wg.Wait()
+}
+
+func TestExchangeMagicLink(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	exchange := func(body string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/magic-link/exchange", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		return rr.Code
+	}
+
+	t.Run("should require the browser token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, exchange(`{"token":"abc"}`))
+	})
+
+	t.Run("should reject unknown links", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, exchange(`{"token":"abc","browser_token":"def"}`))
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// background runs fn in its own goroutine and keeps track of it so the server
+// can wait for it to finish before shutting down.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", fmt.Sprint(err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// periodic runs fn in the background every interval until ctx is cancelled. A
+// panicking run is logged and doesn't stop the next one.
+func (app *application) periodic(ctx context.Context, name string, interval time.Duration, fn func(context.Context)) {
+	if interval <= 0 {
+		return
+	}
+
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				func() {
+					defer func() {
+						if err := recover(); err != nil {
+							app.logger.Errorw("periodic task panicked", "task", name, "error", fmt.Sprint(err))
+						}
+					}()
+
+					fn(ctx)
+				}()
+			}
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type blockListFunc func(ctx context.Context, userID int64, q store.FollowListQuery) ([]*store.BlockedUser, string, error)
+
+// blockUserHandler godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user by ID. Neither of you can see, follow or comment on the other any more, and follows between you are removed.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Block, false)
+}
+
+// unblockUserHandler godoc
+//
+//	@Summary		Unblocks a user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Unblock, true)
+}
+
+// muteUserHandler godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Keeps the posts of a user out of your feed. They are not told and nothing else changes.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Mute, false)
+}
+
+// unmuteUserHandler godoc
+//
+//	@Summary		Unmutes a user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeRelation(w, r, app.store.Blocks.Unmute, true)
+}
+
+// changeRelation blocks, mutes or lifts either for the user in the URL. Only
+// active users can be blocked or muted, but a block or mute is lifted for any
+// account, so that deactivated users can be unblocked too.
+func (app *application) changeRelation(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, userID, otherID int64) error, lift bool) {
+	otherID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	if otherID == user.ID {
+		app.badRequestError(w, r, errors.New("cannot block or mute yourself"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if lift {
+		exists, err := app.store.Users.Exists(ctx, otherID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !exists {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+	} else if _, err := app.getUser(ctx, otherID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := change(ctx, user.ID, otherID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listBlockedHandler godoc
+//
+//	@Summary		Lists blocked users
+//	@Description	Lists the users the current user blocked, most recent first. When there are more, the Link header points to the next page.
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor from the Link header"
+//	@Success		200		{object}	[]store.BlockedUser
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks [get]
+func (app *application) listBlockedHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelations(w, r, app.store.Blocks.GetBlocked)
+}
+
+// listMutedHandler godoc
+//
+//	@Summary		Lists muted users
+//	@Description	Lists the users the current user muted, most recent first. When there are more, the Link header points to the next page.
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor from the Link header"
+//	@Success		200		{object}	[]store.BlockedUser
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [get]
+func (app *application) listMutedHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelations(w, r, app.store.Blocks.GetMuted)
+}
+
+func (app *application) listRelations(w http.ResponseWriter, r *http.Request, list blockListFunc) {
+	q := store.FollowListQuery{
+		Limit: 20,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	users, next, err := list(r.Context(), user.ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.setNextLink(w, r, next, q.Limit)
+
+	if err := app.writeJsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
 )
 
 type CreateCommentPayload struct {
@@ -25,10 +27,16 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if ok, err := app.canViewPost(r.Context(), user, post); err != nil || !ok {
+		app.hiddenPostError(w, r, err)
+		return
+	}
 
 	cms := &store.Comment{
 		Content: payload.Content,
-		UserID:  post.UserID,
+		UserID:  user.ID,
 		PostID:  post.ID,
 	}
 
@@ -40,3 +48,46 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 }
+
+// hideCommentHandler godoc
+//
+//	@Summary		Hides a comment
+//	@Description	Hides a comment on a post from everyone. The comment is kept for the record.
+//	@Tags			posts
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Success		204			{string}	string	"Comment hidden"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) hideCommentHandler(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	comment := &store.Comment{ID: commentID, PostID: getPostFromCtx(r).ID}
+	if err := app.store.Comments.Hide(r.Context(), comment); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditCommentHidden,
+		ActorID:    getUserFromCtx(r).ID,
+		TargetType: "comment",
+		TargetID:   comment.ID,
+		Metadata:   map[string]any{"author_id": comment.UserID, "post_id": comment.PostID, "content": comment.Content},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// listDraftsHandler godoc
+//
+//	@Summary		Lists the drafts of the current user
+//	@Description	Lists the drafts and scheduled posts of the current user, most recently edited first
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/drafts [get]
+func (app *application) listDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.DraftListQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	drafts, err := app.store.Posts.GetDrafts(r.Context(), getUserFromCtx(r).ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, drafts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// publishScheduledPosts publishes the scheduled posts whose time has come, a
+// batch at a time until none are left. Each instance runs it; the store makes
+// sure a post is published once.
+func (app *application) publishScheduledPosts(ctx context.Context) {
+	total := 0
+
+	for ctx.Err() == nil {
+		ids, err := app.store.Posts.PublishDue(ctx, app.config.publisher.batchSize)
+		if err != nil {
+			app.logger.Errorw("error publishing scheduled posts", "error", err)
+			break
+		}
+
+		total += len(ids)
+		if len(ids) < app.config.publisher.batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.logger.Infow("published scheduled posts", "posts", total)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ecetinerdem/gopherSocial/internal/mailer"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type ChangeEmailPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+// changeEmailHandler godoc
+//
+//	@Summary		Requests an email change
+//	@Description	Sends a confirmation link to the new address and a notice to the current one. The email only changes once the link is confirmed.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangeEmailPayload	true	"New email and current password"
+//	@Success		202		{string}	string				"Confirmation link sent"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email [post]
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeEmailPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	// the cached user has no password hash, so read it from the database
+	user, err := app.store.Users.GetUserByID(ctx, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := user.Password.Compare(payload.Password); err != nil {
+		app.unAuthorizedError(w, r, errInvalidCredentials)
+		return
+	}
+
+	if strings.EqualFold(user.Email, payload.Email) {
+		app.badRequestError(w, r, errors.New("the new email is the same as the current one"))
+		return
+	}
+
+	plainToken, err := generateRandomToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.Users.CreateEmailChange(ctx, user.ID, payload.Email, plainToken, app.config.mail.emailChangeExp)
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateEmail:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	confirmVars := struct {
+		Username   string
+		ConfirmURL string
+		ExpiresIn  string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+		ExpiresIn:  fmt.Sprintf("%.0f hours", app.config.mail.emailChangeExp.Hours()),
+	}
+
+	status, err := app.mailer.Send(mailer.EmailChangeConfirmTemplate, user.Username, payload.Email, confirmVars, !isProdEnv)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	noticeVars := struct {
+		Username string
+		NewEmail string
+		ResetURL string
+	}{
+		Username: user.Username,
+		NewEmail: payload.Email,
+		ResetURL: fmt.Sprintf("%s/forgot-password", app.config.frontendURL),
+	}
+
+	app.background(func() {
+		status, err := app.mailer.Send(mailer.EmailChangeNoticeTemplate, user.Username, user.Email, noticeVars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error sending email change notice", "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "status code", status)
+	})
+
+	if err := app.writeJsonResponse(w, http.StatusAccepted, "a confirmation link has been sent to the new email"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// confirmEmailChangeHandler godoc
+//
+//	@Summary		Confirms an email change
+//	@Description	Switches the current user to the new email with the token from the confirmation link
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path		string	true	"Confirmation token"
+//	@Success		204		{string}	string	"Email changed"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email/confirm/{token} [put]
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	user := getUserFromCtx(r)
+
+	err := app.store.Users.ConfirmEmailChange(r.Context(), user.ID, token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestError(w, r, err)
+		case store.ErrDuplicateEmail:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(r.Context(), user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
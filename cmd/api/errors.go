@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -51,3 +55,15 @@ func (app *application) rateLimitExceedResponse(w http.ResponseWriter, r *http.R
 	w.Header().Set("Retry-After", retryAfter)
 	writeJsonError(w, http.StatusTooManyRequests, "rate limit exceed, retry after: "+retryAfter)
 }
+
+func (app *application) payloadTooLargeError(w http.ResponseWriter, r *http.Request, err error) {
+
+	app.logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
+func (app *application) suspendedError(w http.ResponseWriter, r *http.Request, s *store.Suspension) {
+
+	app.logger.Warnw("suspended user", "method", r.Method, "path", r.URL.Path, "until", s.Until)
+	writeJsonError(w, http.StatusForbidden, fmt.Sprintf("account suspended until %s: %s", s.Until.Format(time.RFC3339), s.Reason))
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/blob"
+	"github.com/ecetinerdem/gopherSocial/internal/mailer"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// exportKeyPrefix starts the keys of export archives, which live in
+// app.exportBlobs rather than with the public media.
+const exportKeyPrefix = "exports/"
+
+// requestExportHandler godoc
+//
+//	@Summary		Requests a copy of the user's data
+//	@Description	Builds a zip archive of the profile, posts, comments, followers and following of the current user in the background, and emails a download link when it is ready
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	store.DataExport	"Export queued"
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"An export is already being built"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [post]
+func (app *application) requestExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	export, err := app.store.Exports.Create(r.Context(), user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrDataConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditDataExportRequested,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
+	if err := app.writeJsonResponse(w, http.StatusAccepted, export); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// listExportsHandler godoc
+//
+//	@Summary		Lists the user's data exports
+//	@Description	Lists the exports of the current user that can still be downloaded or are being built
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.DataExport
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [get]
+func (app *application) listExportsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	exports, err := app.store.Exports.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, exports); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// downloadExportHandler godoc
+//
+//	@Summary		Downloads a copy of the user's data
+//	@Description	Returns the zip archive of an export of the current user that is ready and has not expired
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			exportID	path		int		true	"Export ID"
+//	@Success		200			{file}		file	"Export archive"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export/{exportID} [get]
+func (app *application) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportID, err := strconv.ParseInt(chi.URLParam(r, "exportID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	export, err := app.store.Exports.GetReady(ctx, user.ID, exportID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	archive, err := app.exportBlobs.Get(ctx, export.Key)
+	if err != nil {
+		switch err {
+		case blob.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditDataExportDownloaded,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Metadata:   map[string]any{"export_id": export.ID},
+	})
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gophersocial-export-%d.zip"`, export.ID))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// processExports builds queued exports one after the other until none are
+// left. Exports are claimed in the database, so any number of instances can
+// run this side by side.
+func (app *application) processExports(ctx context.Context) {
+	for ctx.Err() == nil {
+		export, err := app.store.Exports.ClaimNext(ctx, app.config.account.exportStaleAfter)
+		if err != nil {
+			if err != store.ErrNotFound {
+				app.logger.Errorw("error claiming data export", "error", err)
+			}
+			return
+		}
+
+		if err := app.buildExport(ctx, export); err != nil {
+			app.logger.Errorw("error building data export", "export_id", export.ID, "user_id", export.UserID, "error", err)
+
+			if err := app.store.Exports.Fail(context.Background(), export.ID); err != nil {
+				app.logger.Errorw("error marking data export failed", "export_id", export.ID, "error", err)
+			}
+		}
+	}
+}
+
+func (app *application) buildExport(ctx context.Context, export *store.DataExport) error {
+	data, err := app.store.Exports.GetData(ctx, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	archive, err := exportArchive(data)
+	if err != nil {
+		return err
+	}
+
+	id, err := generateRandomToken()
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%d/%s.zip", exportKeyPrefix, export.UserID, id)
+
+	if err := app.exportBlobs.Put(ctx, key, "application/zip", archive); err != nil {
+		return err
+	}
+
+	// the archive is only handed out to its owner through the API
+	url := fmt.Sprintf("/v1/users/me/export/%d", export.ID)
+	expiresAt := time.Now().Add(app.config.account.exportTTL)
+
+	if err := app.store.Exports.Complete(ctx, export.ID, key, url, expiresAt); err != nil {
+		app.deleteBlobs([]string{key})
+		// another instance took the export over and finished it
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if err := app.sendExportReady(data.Profile); err != nil {
+		// the export is listed under /users/me/export all the same
+		app.logger.Errorw("error sending data export email", "export_id", export.ID, "error", err)
+	}
+
+	return nil
+}
+
+// exportArchive packs data into a zip file with one JSON document per part.
+func exportArchive(data *store.ExportData) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", data.Profile},
+		{"posts.json", data.Posts},
+		{"comments.json", data.Comments},
+		{"followers.json", data.Followers},
+		{"following.json", data.Following},
+	}
+
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// pruneExports deletes the archives whose download window has passed.
+func (app *application) pruneExports(ctx context.Context) {
+	keys, err := app.store.Exports.DeleteExpired(ctx)
+	if err != nil {
+		app.logger.Errorw("error pruning data exports", "error", err)
+		return
+	}
+
+	app.deleteBlobs(keys)
+}
+
+func (app *application) sendExportReady(user *store.User) error {
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username    string
+		DownloadURL string
+		ExpiresIn   string
+	}{
+		Username:    user.Username,
+		DownloadURL: fmt.Sprintf("%s/settings/account", app.config.frontendURL),
+		ExpiresIn:   fmt.Sprintf("%.0f days", app.config.account.exportTTL.Hours()/24),
+	}
+
+	status, err := app.mailer.Send(mailer.DataExportReadyTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecetinerdem/gopherSocial/internal/blob"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+func TestExportArchive(t *testing.T) {
+	data := &store.ExportData{
+		Profile:   &store.User{ID: 1, Username: "gopher"},
+		Posts:     []*store.ExportPost{{ID: 1, Title: "Hello"}},
+		Comments:  []*store.ExportComment{},
+		Followers: []*store.ExportFollow{{UserID: 2, Username: "friend"}},
+		Following: []*store.ExportFollow{},
+	}
+
+	archive, err := exportArchive(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	for _, name := range []string{"profile.json", "posts.json", "comments.json", "followers.json", "following.json"} {
+		if files[name] == nil {
+			t.Errorf("expected %s in the archive", name)
+		}
+	}
+
+	rc, err := files["posts.json"].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	var posts []*store.ExportPost
+	if err := json.NewDecoder(rc).Decode(&posts); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Title != "Hello" {
+		t.Errorf("unexpected posts %+v", posts)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	t.Run("should require a confirmation", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, send(http.MethodDelete, "/v1/users/me", `{}`).Code)
+		checkResponseCode(t, http.StatusUnauthorized, send(http.MethodDelete, "/v1/users/me", `{"password":"wrong"}`).Code)
+		checkResponseCode(t, http.StatusUnauthorized, send(http.MethodDelete, "/v1/users/me", `{"code":"000000"}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, send(http.MethodDelete, "/v1/users/me", `{"code":"abc"}`).Code)
+	})
+
+	t.Run("should confirm with a recent sign-in", func(t *testing.T) {
+		app.store.Sessions = &recentSessionStore{recent: true}
+		defer func() { app.store.Sessions = &store.MockSessionStore{} }()
+
+		checkResponseCode(t, http.StatusAccepted, send(http.MethodDelete, "/v1/users/me", `{}`).Code)
+	})
+
+	t.Run("should not cancel a deletion that was never scheduled", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodDelete, "/v1/users/me/deletion", "").Code)
+	})
+
+	t.Run("should queue an export", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, send(http.MethodPost, "/v1/users/me/export", "").Code)
+	})
+}
+
+type readyExportStore struct {
+	store.MockExportStore
+}
+
+func (s *readyExportStore) GetReady(ctx context.Context, userID, exportID int64) (*store.DataExport, error) {
+	if exportID != 3 {
+		return nil, store.ErrNotFound
+	}
+	return &store.DataExport{ID: exportID, UserID: userID, Status: store.ExportReady, Key: "exports/1/abc.zip"}, nil
+}
+
+func TestDownloadExport(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	exportBlobs, err := blob.NewLocalStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exportBlobs.Put(context.Background(), "exports/1/abc.zip", "application/zip", []byte("zip bytes")); err != nil {
+		t.Fatal(err)
+	}
+
+	app.exportBlobs = exportBlobs
+	app.store.Exports = &readyExportStore{}
+
+	t.Run("should return the archive to its owner", func(t *testing.T) {
+		res := send(http.MethodGet, "/v1/users/me/export/3", "").Result()
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		if got := res.Header.Get("Content-Type"); got != "application/zip" {
+			t.Errorf("expected an application/zip response but got %q", got)
+		}
+		if got := res.Header.Get("Content-Disposition"); !strings.HasPrefix(got, "attachment;") {
+			t.Errorf("expected an attachment but got %q", got)
+		}
+
+		var body bytes.Buffer
+		body.ReadFrom(res.Body)
+		if body.String() != "zip bytes" {
+			t.Errorf("expected the archive but got %q", body.String())
+		}
+	})
+
+	t.Run("should not find other exports", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodGet, "/v1/users/me/export/4", "").Code)
+	})
+
+	t.Run("should not be served without a token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/export/3", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, app.mount())
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -45,8 +45,9 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	ctx := r.Context()
+	user := getUserFromCtx(r)
 
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(42), pfq) //random userID here change later on
+	feed, err := app.store.Posts.GetUserFeed(ctx, user.ID, pfq)
 
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type followListFunc func(ctx context.Context, userID, viewerID int64, q store.FollowListQuery) ([]*store.FollowListEntry, string, error)
+
+// getFollowersHandler godoc
+//
+//	@Summary		Lists the followers of a user
+//	@Description	Lists the users following a user, most recent first. When there are more, the Link header points to the next page.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor from the Link header"
+//	@Success		200		{object}	[]store.FollowListEntry
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.GetFollowers)
+}
+
+// getFollowingHandler godoc
+//
+//	@Summary		Lists the users a user follows
+//	@Description	Lists the users a user follows, most recent first. When there are more, the Link header points to the next page.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor from the Link header"
+//	@Success		200		{object}	[]store.FollowListEntry
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.GetFollowing)
+}
+
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, list followListFunc) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	q := store.FollowListQuery{
+		Limit: 20,
+	}
+
+	q, err = q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.getVisibleUser(ctx, userID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	viewer := getUserFromCtx(r)
+
+	entries, next, err := list(ctx, userID, viewer.ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.setNextLink(w, r, next, q.Limit)
+
+	if err := app.writeJsonResponse(w, http.StatusOK, entries); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// setNextLink points the Link header at the page after this one, unless this
+// is the last.
+func (app *application) setNextLink(w http.ResponseWriter, r *http.Request, cursor string, limit int) {
+	if cursor == "" {
+		return
+	}
+
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	query.Set("limit", strconv.Itoa(limit))
+	w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?%s>; rel="next"`, app.config.apiURL, r.URL.Path, query.Encode()))
+}
+
+// listFollowRequestsHandler godoc
+//
+//	@Summary		Lists follow requests
+//	@Description	Lists the pending requests to follow the current user, most recent first. When there are more, the Link header points to the next page.
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor from the Link header"
+//	@Success		200		{object}	[]store.FollowListEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) listFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.FollowListQuery{
+		Limit: 20,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	entries, next, err := app.store.Followers.GetFollowRequests(r.Context(), user.ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.setNextLink(w, r, next, q.Limit)
+
+	if err := app.writeJsonResponse(w, http.StatusOK, entries); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// approveFollowRequestHandler godoc
+//
+//	@Summary		Approves a follow request
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"ID of the user who asked"
+//	@Success		204		{string}	string	"Request approved"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/approve [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.ApproveFollowRequest)
+}
+
+// rejectFollowRequestHandler godoc
+//
+//	@Summary		Rejects a follow request
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"ID of the user who asked"
+//	@Success		204		{string}	string	"Request rejected"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID} [delete]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.RejectFollowRequest)
+}
+
+func (app *application) answerFollowRequest(w http.ResponseWriter, r *http.Request, answer func(ctx context.Context, userID, requesterID int64) error) {
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := answer(r.Context(), user.ID, requesterID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
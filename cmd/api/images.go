@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ecetinerdem/gopherSocial/internal/images"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// Sizes profile images are rendered in. Avatars are square, banners 3:1.
+var imageVariants = map[string][]images.Variant{
+	store.UserImageAvatar: {
+		{Name: "48", Width: 48, Height: 48},
+		{Name: "128", Width: 128, Height: 128},
+		{Name: "400", Width: 400, Height: 400},
+	},
+	store.UserImageBanner: {
+		{Name: "600", Width: 600, Height: 200},
+		{Name: "1500", Width: 1500, Height: 500},
+	},
+}
+
+// putImageHandler godoc
+//
+//	@Summary		Uploads a profile image
+//	@Description	Takes a JPEG, PNG or GIF as the raw request body, whatever its Content-Type, and replaces the avatar or banner with it. The image is re-encoded without its metadata and rendered in several sizes.
+//	@Tags			users
+//	@Accept			image/jpeg,image/png,image/gif
+//	@Produce		json
+//	@Success		200	{object}	map[string]string	"URL of each size"
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		413	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/avatar [put]
+//	@Router			/users/me/banner [put]
+func (app *application) putImageHandler(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := app.config.media.maxUploadBytes
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				app.payloadTooLargeError(w, r, fmt.Errorf("images are limited to %d bytes", limit))
+				return
+			}
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		// a decoded image takes four bytes a pixel, so only a few are
+		// processed at once and the rest wait their turn
+		select {
+		case app.imageSlots <- struct{}{}:
+		case <-r.Context().Done():
+			app.internalServerError(w, r, r.Context().Err())
+			return
+		}
+		renditions, err := images.Process(data, app.config.media.maxPixels, imageVariants[kind])
+		<-app.imageSlots
+		if err != nil {
+			switch err {
+			case images.ErrUnsupportedType, images.ErrTooManyPixels:
+				app.badRequestError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		id, err := generateRandomToken()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		user := getUserFromCtx(r)
+
+		urls := make(map[string]string, len(renditions))
+		keys := make([]string, 0, len(renditions))
+		for _, rendition := range renditions {
+			// a fresh prefix per upload lets caches keep every URL forever
+			key := fmt.Sprintf("%ss/%d/%s/%s%s", kind, user.ID, id, rendition.Name, rendition.Ext)
+
+			if err := app.blobs.Put(ctx, key, rendition.ContentType, rendition.Data); err != nil {
+				app.deleteBlobs(keys)
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			urls[rendition.Name] = app.blobs.URL(key)
+			keys = append(keys, key)
+		}
+
+		old, err := app.store.Users.SetImage(ctx, user.ID, kind, urls, keys)
+		if err != nil {
+			app.deleteBlobs(keys)
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		app.deleteBlobs(old)
+		app.invalidateUser(ctx, user.ID)
+
+		if err := app.writeJsonResponse(w, http.StatusOK, urls); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+// deleteImageHandler godoc
+//
+//	@Summary		Removes a profile image
+//	@Tags			users
+//	@Produce		json
+//	@Success		204	{string}	string	"Image removed"
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/avatar [delete]
+//	@Router			/users/me/banner [delete]
+func (app *application) deleteImageHandler(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := getUserFromCtx(r)
+
+		old, err := app.store.Users.SetImage(ctx, user.ID, kind, nil, nil)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		app.deleteBlobs(old)
+		app.invalidateUser(ctx, user.ID)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// deleteBlobs removes blobs that are no longer referenced. It runs in the
+// background; a blob that cannot be removed is only wasted space.
+func (app *application) deleteBlobs(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	app.background(func() {
+		for _, key := range keys {
+			blobs := app.blobs
+			if strings.HasPrefix(key, exportKeyPrefix) {
+				blobs = app.exportBlobs
+			}
+			if err := blobs.Delete(context.Background(), key); err != nil {
+				app.logger.Errorw("error deleting blob", "key", key, "error", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ecetinerdem/gopherSocial/internal/auth"
+)
+
+// jwksHandler godoc
+//
+//	@Summary		Fetches the token signing keys
+//	@Description	Public keys other services use to verify our access tokens (RFC 7517)
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKS
+//	@Failure		404	{object}	error
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.authenticator.(auth.KeySetProvider)
+
+	if !ok {
+		app.notFoundError(w, r, errors.New("authenticator does not publish a key set"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	// served without the data envelope so standard JWKS clients can read it
+	if err := writeJson(w, http.StatusOK, provider.JWKS()); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
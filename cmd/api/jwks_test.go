@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ecetinerdem/gopherSocial/internal/auth"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	get := func() *http.Response {
+		req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, app.mount())
+		return rr.Result()
+	}
+
+	t.Run("should not be found for shared secret tokens", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, get().StatusCode)
+	})
+
+	t.Run("should publish the verification keys", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		authenticator, err := auth.NewEdDSAAuthenticator("gophersocial", "gophersocial", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.authenticator = authenticator
+
+		res := get()
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		if got := res.Header.Get("Cache-Control"); got != "public, max-age=300" {
+			t.Errorf("expected the key set to be cacheable but got %q", got)
+		}
+
+		// standard clients expect the key set at the top level
+		var set auth.JWKS
+		if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+			t.Fatal(err)
+		}
+
+		want := authenticator.JWKS().Keys
+		if len(set.Keys) != 1 || set.Keys[0] != want[0] {
+			t.Errorf("expected %+v but got %+v", want, set.Keys)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/mailer"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// dummyUser has a password that is compared against when the email is unknown,
+// so a failed login takes as long whether or not the account exists.
+var dummyUser = sync.OnceValue(func() *store.User {
+	user := &store.User{}
+	user.Password.Set("gopher-dummy-password")
+	return user
+})
+
+// loginSubjects returns the account and IP address a login attempt is counted
+// against. Accounts are keyed by email, so unknown emails are throttled exactly
+// like existing ones and the responses don't reveal which accounts exist.
+func loginSubjects(email, ip string) map[string]string {
+	return map[string]string{
+		store.LoginScopeAccount: loginAccountKey(email),
+		store.LoginScopeIP:      ip,
+	}
+}
+
+func loginAccountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// secondFactorSubjects returns the user and IP address a wrong second factor is
+// counted against. The caller already knows the password, so every challenge
+// shares the user's counter and a fresh login does not buy more guesses.
+func secondFactorSubjects(userID int64, ip string) map[string]string {
+	return map[string]string{
+		store.LoginScopeTwoFactor: strconv.FormatInt(userID, 10),
+		store.LoginScopeIP:        ip,
+	}
+}
+
+// loginRetryAfter reports how long the caller has to wait before it may try to
+// log in again, either because a subject is locked out or because of the
+// progressive delay after repeated failures.
+func (app *application) loginRetryAfter(ctx context.Context, subjects map[string]string) (time.Duration, error) {
+	var wait time.Duration
+	now := time.Now()
+
+	for scope, subject := range subjects {
+		lf, err := app.store.LoginFailures.Get(ctx, scope, subject)
+		if err != nil {
+			if err == store.ErrNotFound {
+				continue
+			}
+			return 0, err
+		}
+
+		if lf.LockedUntil != nil && lf.LockedUntil.After(now) {
+			wait = max(wait, lf.LockedUntil.Sub(now))
+			continue
+		}
+
+		if until := lf.LastFailureAt.Add(app.loginDelay(lf.Failures)); until.After(now) {
+			wait = max(wait, until.Sub(now))
+		}
+	}
+
+	return wait, nil
+}
+
+// loginDelay doubles the wait with every failure past the free attempts.
+func (app *application) loginDelay(failures int) time.Duration {
+	cfg := app.config.auth.lockout
+
+	extra := failures - cfg.freeAttempts
+	if extra <= 0 || cfg.baseDelay <= 0 {
+		return 0
+	}
+
+	delay := cfg.baseDelay
+	for i := 1; i < extra && delay < cfg.maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, cfg.maxDelay)
+}
+
+// recordLoginFailure counts a failed login against subjects, and emails the
+// owner when it locks their account. user is nil when the email is unknown.
+func (app *application) recordLoginFailure(ctx context.Context, subjects map[string]string, ip string, user *store.User) error {
+	cfg := app.config.auth.lockout
+
+	for scope, subject := range subjects {
+		threshold := cfg.accountThreshold
+		if scope == store.LoginScopeIP {
+			threshold = cfg.ipThreshold
+		}
+
+		lf, err := app.store.LoginFailures.Record(ctx, scope, subject, cfg.duration, threshold, cfg.duration)
+		if err != nil {
+			return err
+		}
+
+		if scope != store.LoginScopeIP && user != nil && threshold > 0 && lf.Failures == threshold {
+			app.logger.Warnw("account locked after failed logins", "user_id", user.ID, "ip", ip)
+
+			app.background(func() {
+				if err := app.sendAccountLocked(user, ip); err != nil {
+					app.logger.Errorw("error sending account locked email", "error", err)
+				}
+			})
+		}
+	}
+
+	return nil
+}
+
+// resetLoginFailures clears the counters of the account after a complete
+// login, second factor included.
+func (app *application) resetLoginFailures(ctx context.Context, user *store.User) error {
+	if err := app.store.LoginFailures.Reset(ctx, store.LoginScopeAccount, loginAccountKey(user.Email)); err != nil {
+		return err
+	}
+	return app.store.LoginFailures.Reset(ctx, store.LoginScopeTwoFactor, strconv.FormatInt(user.ID, 10))
+}
+
+func (app *application) sendAccountLocked(user *store.User, ip string) error {
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username  string
+		LockedFor string
+		IP        string
+		ResetURL  string
+	}{
+		Username:  user.Username,
+		LockedFor: fmt.Sprintf("%.0f minutes", app.config.auth.lockout.duration.Minutes()),
+		IP:        ip,
+		ResetURL:  fmt.Sprintf("%s/forgot-password", app.config.frontendURL),
+	}
+
+	status, err := app.mailer.Send(mailer.AccountLockedTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ecetinerdem/gopherSocial/internal/mailer"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+type RequestMagicLinkPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// MagicLinkRequest holds the secret that binds an emailed link to the client
+// that asked for it. It is returned in the body rather than set as a cookie
+// because the frontend calls the API cross-origin without credentials.
+type MagicLinkRequest struct {
+	BrowserToken string `json:"browser_token"`
+}
+
+type ExchangeMagicLinkPayload struct {
+	Token        string `json:"token" validate:"required,max=255"`
+	BrowserToken string `json:"browser_token" validate:"required,max=255"`
+}
+
+// requestMagicLinkHandler godoc
+//
+//	@Summary		Requests a sign-in link
+//	@Description	Emails a single-use sign-in link. The returned browser token must be kept by the client and sent along when the link is exchanged. The response is the same whether or not the email belongs to an account.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RequestMagicLinkPayload	true	"Account email"
+//	@Success		202		{object}	MagicLinkRequest		"Sign-in link sent if the account exists"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/magic-link [post]
+func (app *application) requestMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RequestMagicLinkPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	for _, key := range []string{"ip:" + clientIP(r), "email:" + loginAccountKey(payload.Email)} {
+		if allow, retryAfter := app.magicLimiter.Allow(key); !allow {
+			app.rateLimitExceedResponse(w, r, retryAfter.String())
+			return
+		}
+	}
+
+	browserToken, err := generateRandomToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the lookup runs in the background so neither the status nor the
+	// response time tells the caller whether the email is registered
+	app.background(func() {
+		if err := app.sendMagicLink(context.Background(), payload.Email, browserToken); err != nil {
+			app.logger.Errorw("error sending magic link", "error", err)
+		}
+	})
+
+	if err := app.writeJsonResponse(w, http.StatusAccepted, &MagicLinkRequest{BrowserToken: browserToken}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// exchangeMagicLinkHandler godoc
+//
+//	@Summary		Signs in with a sign-in link
+//	@Description	Exchanges the token from a sign-in link, together with the browser token returned when it was requested, for a token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ExchangeMagicLinkPayload	true	"Link and browser token"
+//	@Success		201		{object}	AuthTokens					"Tokens"
+//	@Success		202		{object}	TwoFactorChallenge			"Two-factor code required"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/magic-link/exchange [post]
+func (app *application) exchangeMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ExchangeMagicLinkPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	userID, err := app.store.MagicLinks.Consume(ctx, payload.Token, payload.BrowserToken)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.audit(r, &store.AuditEvent{
+				Event:    store.AuditLoginFailed,
+				Metadata: map[string]any{"reason": "invalid_magic_link"},
+			})
+			app.unAuthorizedError(w, r, errors.New("invalid or expired sign-in link"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.getSignInUser(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedError(w, r, errors.New("invalid or expired sign-in link"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.Suspended() {
+		app.suspendedError(w, r, user.Suspension)
+		return
+	}
+
+	challenge, err := app.twoFactorChallenge(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if challenge != nil {
+		if err := app.writeJsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	tokens, err := app.createSession(r, userID, "magic_link")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// revokeMagicLinksHandler godoc
+//
+//	@Summary		Revokes sign-in links
+//	@Description	Invalidates every sign-in link of the current user that has not been used yet
+//	@Tags			users
+//	@Produce		json
+//	@Success		204	{string}	string	"Sign-in links revoked"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/magic-links [delete]
+func (app *application) revokeMagicLinksHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.store.MagicLinks.RevokeAllForUser(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) sendMagicLink(ctx context.Context, email, browserToken string) error {
+	user, err := app.store.Users.GetByEmail(ctx, email)
+	if err == store.ErrNotFound {
+		// exchanging the link reactivates an account its owner deactivated
+		user, err = app.store.Users.GetDeactivatedByEmail(ctx, email, app.config.account.reactivationWindow)
+	}
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	plainToken, err := generateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	exp := app.config.auth.magicLink.exp
+	if err := app.store.MagicLinks.Create(ctx, user.ID, plainToken, browserToken, exp); err != nil {
+		return err
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username  string
+		LoginURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		LoginURL:  fmt.Sprintf("%s/magic-link/%s", app.config.frontendURL, plainToken),
+		ExpiresIn: fmt.Sprintf("%.0f minutes", exp.Minutes()),
+	}
+
+	status, err := app.mailer.Send(mailer.MagicLinkTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	return nil
+}
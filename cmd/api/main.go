@@ -2,18 +2,23 @@ package main
 
 import (
 	"expvar"
+	"fmt"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/ecetinerdem/gopherSocial/internal/auth"
+	"github.com/ecetinerdem/gopherSocial/internal/blob"
 	"github.com/ecetinerdem/gopherSocial/internal/db"
 	"github.com/ecetinerdem/gopherSocial/internal/env"
 	"github.com/ecetinerdem/gopherSocial/internal/mailer"
+	"github.com/ecetinerdem/gopherSocial/internal/oidc"
 	"github.com/ecetinerdem/gopherSocial/internal/ratelimiter"
 	"github.com/ecetinerdem/gopherSocial/internal/store"
 	"github.com/ecetinerdem/gopherSocial/internal/store/cache"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const version = ""
@@ -56,8 +61,10 @@ func main() {
 		},
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
-			fromEmail: env.GetString("FROM_EMAIL", ""),
-			exp:       time.Hour * 24 * 3, //3 days
+			fromEmail:      env.GetString("FROM_EMAIL", ""),
+			exp:            time.Hour * 24 * 3, //3 days
+			resetExp:       time.Hour,
+			emailChangeExp: time.Hour * 24,
 			sendGrid: sendGridConfig{
 				apiKey: env.GetString("SENDGRID_API_KEY", ""),
 			},
@@ -68,9 +75,44 @@ func main() {
 				pass: env.GetString("AUTH_BASIC_PASS", "admin"),
 			},
 			token: tokenConfig{
-				secret: env.GetString("AUTH_TOKEN_SECRET", "examplesecret"),
-				expiry: time.Hour * 24 * 3,
-				iss:    env.GetString("AUTH_TOKEN_ISS", "gopher"),
+				alg:           env.GetString("AUTH_TOKEN_ALG", "HS256"),
+				keyFiles:      strings.Split(env.GetString("AUTH_TOKEN_KEY_FILES", ""), ","),
+				secret:        env.GetString("AUTH_TOKEN_SECRET", "examplesecret"),
+				expiry:        env.GetDuration("AUTH_TOKEN_EXP", "15m"),
+				refreshExpiry: env.GetDuration("AUTH_REFRESH_TOKEN_EXP", "720h"), //30 days
+				iss:           env.GetString("AUTH_TOKEN_ISS", "gopher"),
+			},
+			twoFactor: twoFactorConfig{
+				issuer:       env.GetString("TOTP_ISSUER", "GopherSocial"),
+				challengeExp: time.Minute * 5,
+			},
+			lockout: lockoutConfig{
+				freeAttempts:     3,
+				baseDelay:        time.Second,
+				maxDelay:         time.Second * 30,
+				accountThreshold: env.GetInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+				ipThreshold:      env.GetInt("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+				duration:         env.GetDuration("LOGIN_LOCKOUT_DURATION", "15m"),
+			},
+			activation: activationConfig{
+				resendLimit:   3,
+				resendWindow:  time.Hour,
+				gracePeriod:   env.GetDuration("ACTIVATION_GRACE_PERIOD", "168h"), //7 days
+				sweepInterval: env.GetDuration("ACTIVATION_SWEEP_INTERVAL", "1h"),
+			},
+			sessions: sessionConfig{
+				retention:     env.GetDuration("SESSION_RETENTION", "24h"),
+				sweepInterval: env.GetDuration("SESSION_SWEEP_INTERVAL", "1h"),
+			},
+			password: passwordConfig{
+				memory:      env.GetInt("PASSWORD_ARGON2_MEMORY", 64*1024), //KiB
+				iterations:  env.GetInt("PASSWORD_ARGON2_ITERATIONS", 3),
+				parallelism: env.GetInt("PASSWORD_ARGON2_PARALLELISM", 2),
+			},
+			magicLink: magicLinkConfig{
+				exp:         env.GetDuration("MAGIC_LINK_EXP", "15m"),
+				limit:       5,
+				limitWindow: time.Hour,
 			},
 		},
 		rateLimiter: ratelimiter.Config{
@@ -78,7 +120,53 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", false),
 		},
+		account: accountConfig{
+			deletionGrace:      env.GetDuration("ACCOUNT_DELETION_GRACE", "720h"), //30 days
+			sweepInterval:      env.GetDuration("ACCOUNT_SWEEP_INTERVAL", "1h"),
+			exportTTL:          env.GetDuration("EXPORT_TTL", "168h"), //7 days
+			exportPollInterval: env.GetDuration("EXPORT_POLL_INTERVAL", "30s"),
+			exportStaleAfter:   env.GetDuration("EXPORT_STALE_AFTER", "15m"),
+			reactivationWindow: env.GetDuration("ACCOUNT_REACTIVATION_WINDOW", "720h"),
+			reauthWindow:       env.GetDuration("ACCOUNT_REAUTH_WINDOW", "10m"),
+		},
+		suggestions: suggestionsConfig{
+			maxAge:        env.GetDuration("SUGGESTIONS_MAX_AGE", "24h"),
+			batchSize:     env.GetInt("SUGGESTIONS_BATCH_SIZE", 100),
+			batchInterval: env.GetDuration("SUGGESTIONS_BATCH_INTERVAL", "5m"),
+		},
+		publisher: publisherConfig{
+			interval:  env.GetDuration("PUBLISHER_INTERVAL", "30s"),
+			batchSize: env.GetInt("PUBLISHER_BATCH_SIZE", 100),
+		},
+		media: mediaConfig{
+			backend:        env.GetString("MEDIA_BACKEND", "local"),
+			maxUploadBytes: int64(env.GetInt("MEDIA_MAX_UPLOAD_BYTES", 8<<20)),
+			maxPixels:      env.GetInt("MEDIA_MAX_PIXELS", 16_000_000),
+			maxConcurrent:  env.GetInt("MEDIA_MAX_CONCURRENT", 2),
+			local: localMediaConfig{
+				dir:     env.GetString("MEDIA_LOCAL_DIR", "./media"),
+				baseURL: env.GetString("MEDIA_LOCAL_URL", "http://localhost:8080/media"),
+			},
+			s3: blob.S3Config{
+				Endpoint:  env.GetString("MEDIA_S3_ENDPOINT", "http://localhost:9000"),
+				Region:    env.GetString("MEDIA_S3_REGION", "us-east-1"),
+				Bucket:    env.GetString("MEDIA_S3_BUCKET", "gopher-media"),
+				AccessKey: env.GetString("MEDIA_S3_ACCESS_KEY", ""),
+				SecretKey: env.GetString("MEDIA_S3_SECRET_KEY", ""),
+				PublicURL: env.GetString("MEDIA_S3_PUBLIC_URL", ""),
+			},
+			exportsDir:    env.GetString("EXPORTS_LOCAL_DIR", "./exports"),
+			exportsBucket: env.GetString("EXPORTS_S3_BUCKET", "gopher-exports"),
+		},
+		audit: auditConfig{
+			queueSize:     env.GetInt("AUDIT_QUEUE_SIZE", 1024),
+			batchSize:     100,
+			flushInterval: time.Second,
+			retention:     env.GetDuration("AUDIT_RETENTION", "2160h"), //90 days
+			sweepInterval: env.GetDuration("AUDIT_SWEEP_INTERVAL", "24h"),
+		},
 	}
+	cfg.oidc = oidcConfigs(cfg.apiURL)
 
 	//Logger
 	logger := zap.Must(zap.NewProduction()).Sugar()
@@ -106,21 +194,61 @@ func main() {
 		logger.Info("redis cache connection established")
 	}
 
-	JWTAuthenticator := auth.NewJWTAuthenticator(
-		cfg.auth.token.secret,
-		cfg.auth.token.iss,
-		cfg.auth.token.iss,
-	)
+	authenticator, err := newAuthenticator(cfg.auth.token)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infow("token authenticator configured", "alg", cfg.auth.token.alg)
+
+	blobs, err := newBlobStore(cfg.media)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infow("media storage configured", "backend", cfg.media.backend)
+
+	exportBlobs, err := newExportStore(cfg.media)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	mailer := mailer.NewSendGrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
 
+	store.SetPasswordHasher(auth.NewPasswordHashers(
+		auth.NewArgon2idHasher(
+			uint32(cfg.auth.password.memory),
+			uint32(cfg.auth.password.iterations),
+			uint8(cfg.auth.password.parallelism),
+		),
+		auth.BcryptHasher{Cost: bcrypt.DefaultCost},
+	))
+
+	// created before the store variable shadows the package
+	auditEvents := make(chan *store.AuditEvent, cfg.audit.queueSize)
+
 	store := store.NewStore(db)
 	cacheStorage := cache.NewRedisStorage(rdb)
 
+	oidcProviders := make(map[string]*oidc.Provider)
+	for _, providerCfg := range cfg.oidc {
+		oidcProviders[providerCfg.Name] = oidc.NewProvider(providerCfg)
+		logger.Infow("identity provider configured", "provider", providerCfg.Name, "issuer", providerCfg.Issuer)
+	}
+
 	rateLimiter := ratelimiter.NewFixedWindowLimiter(
 		cfg.rateLimiter.RequestsPerTimeFrame,
 		cfg.rateLimiter.TimeFrame,
 	)
 
+	resendLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.auth.activation.resendLimit,
+		cfg.auth.activation.resendWindow,
+	)
+
+	magicLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.auth.magicLink.limit,
+		cfg.auth.magicLink.limitWindow,
+	)
+
 	//Application
 	app := &application{
 		config:        cfg,
@@ -128,8 +256,15 @@ func main() {
 		cacheStorage:  cacheStorage,
 		logger:        logger,
 		mailer:        mailer,
-		authenticator: JWTAuthenticator,
+		authenticator: authenticator,
 		rateLimiter:   rateLimiter,
+		resendLimiter: resendLimiter,
+		magicLimiter:  magicLimiter,
+		auditEvents:   auditEvents,
+		blobs:         blobs,
+		exportBlobs:   exportBlobs,
+		imageSlots:    make(chan struct{}, max(cfg.media.maxConcurrent, 1)),
+		oidcProviders: oidcProviders,
 	}
 
 	//metrics collected
@@ -147,3 +282,83 @@ func main() {
 
 	logger.Fatal(app.run(mux))
 }
+
+// newAuthenticator builds the token authenticator for the configured algorithm.
+// With RS256 and EdDSA the first key file signs new tokens and the remaining
+// ones only verify, so a key can be rotated out without logging everyone out.
+func newAuthenticator(cfg tokenConfig) (auth.Authenticator, error) {
+	switch cfg.alg {
+	case "RS256":
+		keys, err := auth.LoadRSAPrivateKeys(cfg.keyFiles)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewRS256Authenticator(cfg.iss, cfg.iss, keys...)
+	case "EdDSA":
+		keys, err := auth.LoadEdDSAPrivateKeys(cfg.keyFiles)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewEdDSAAuthenticator(cfg.iss, cfg.iss, keys...)
+	default:
+		return auth.NewJWTAuthenticator(cfg.secret, cfg.iss, cfg.iss), nil
+	}
+}
+
+// newBlobStore builds the storage for uploaded media: files on local disk, or
+// a bucket on an S3 compatible service.
+func newBlobStore(cfg mediaConfig) (blob.Store, error) {
+	switch cfg.backend {
+	case "s3":
+		return blob.NewS3Store(cfg.s3)
+	case "local":
+		return blob.NewLocalStore(cfg.local.dir, cfg.local.baseURL)
+	default:
+		return nil, fmt.Errorf("unknown media backend %q", cfg.backend)
+	}
+}
+
+// newExportStore builds the storage for data exports on the same backend as
+// the media. Exports are only handed out through the API, so they go to a
+// directory that is not served or to a bucket of their own.
+func newExportStore(cfg mediaConfig) (blob.Store, error) {
+	switch cfg.backend {
+	case "s3":
+		s3 := cfg.s3
+		s3.Bucket = cfg.exportsBucket
+		s3.PublicURL = ""
+		return blob.NewS3Store(s3)
+	case "local":
+		return blob.NewLocalStore(cfg.exportsDir, "")
+	default:
+		return nil, fmt.Errorf("unknown media backend %q", cfg.backend)
+	}
+}
+
+// oidcConfigs reads the identity providers listed in OIDC_PROVIDERS. Each one
+// is configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and
+// optionally _REDIRECT_URL and _SCOPES.
+func oidcConfigs(apiURL string) []oidc.Config {
+	var cfgs []oidc.Config
+
+	for _, name := range strings.Split(env.GetString("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		defaultRedirect := fmt.Sprintf("http://%s/v1/authentication/oidc/%s/callback", apiURL, name)
+
+		cfgs = append(cfgs, oidc.Config{
+			Name:         name,
+			Issuer:       env.GetString(prefix+"ISSUER", ""),
+			ClientID:     env.GetString(prefix+"CLIENT_ID", ""),
+			ClientSecret: env.GetString(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  env.GetString(prefix+"REDIRECT_URL", defaultRedirect),
+			Scopes:       strings.Fields(env.GetString(prefix+"SCOPES", "openid email profile")),
+		})
+	}
+
+	return cfgs
+}
@@ -67,6 +67,12 @@ func (app *application) AuthTokenMiddleWare(next http.Handler) http.Handler {
 		}
 
 		token := parts[1]
+
+		if strings.HasPrefix(token, apiKeyPrefix) {
+			app.apiKeyAuth(w, r, next, token)
+			return
+		}
+
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unAuthorizedError(w, r, err)
@@ -81,53 +87,58 @@ func (app *application) AuthTokenMiddleWare(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := r.Context()
+		sessionID, _ := claims["sid"].(string)
 
-		user, err := app.getUser(ctx, userID)
-		if err != nil {
-			app.unAuthorizedError(w, r, err)
+		if sessionID == "" {
+			app.unAuthorizedError(w, r, fmt.Errorf("token has no session"))
 			return
 		}
 
-		ctx = context.WithValue(ctx, userCtx, user)
-
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
-func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user := getUserFromCtx(r)
-		post := getPostFromCtx(r)
+		ctx := r.Context()
 
-		if post.UserID == user.ID {
-			next.ServeHTTP(w, r)
+		active, err := app.store.Sessions.Touch(ctx, sessionID)
+		if err != nil {
+			app.internalServerError(w, r, err)
 			return
 		}
 
-		allowed, err := app.checkRolePresedence(r.Context(), user, requiredRole)
+		if !active {
+			app.unAuthorizedError(w, r, fmt.Errorf("session revoked"))
+			return
+		}
 
+		user, err := app.getUser(ctx, userID)
 		if err != nil {
-			app.internalServerError(w, r, err)
+			app.unAuthorizedError(w, r, err)
 			return
 		}
 
-		if !allowed {
-			app.forbiddenError(w, r)
+		if user.Suspended() {
+			app.suspendedError(w, r, user.Suspension)
 			return
 		}
-		next.ServeHTTP(w, r)
+
+		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, sessionID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (app *application) checkRolePresedence(ctx context.Context, user *store.User, requiredRole string) (bool, error) {
-	role, err := app.store.Roles.GetByName(ctx, requiredRole)
+// RequireScope rejects requests made with an API key that was not granted
+// scope. Access tokens from a login are not restricted.
+func (app *application) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := getAPIKeyFromCtx(r)
 
-	if err != nil {
-		return false, err
+			if key != nil && !key.HasScope(scope) {
+				app.forbiddenError(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
-
-	return user.Role.Level >= role.Level, nil
 }
 
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
@@ -153,6 +164,29 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 	return user, nil
 }
 
+// getVisibleUser is getUser for a user shown to someone else, to whom a
+// suspended user does not exist.
+func (app *application) getVisibleUser(ctx context.Context, userID int64) (*store.User, error) {
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Suspended() {
+		return nil, store.ErrNotFound
+	}
+	return user, nil
+}
+
+// invalidateUser drops the cached copy of a user after it changed.
+func (app *application) invalidateUser(ctx context.Context, userID int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	app.cacheStorage.Users.Delete(ctx, userID)
+}
+
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/oidc"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateExp    = time.Minute * 10
+)
+
+var usernameSanitizer = regexp.MustCompile(`[^a-z0-9_.]+`)
+
+// oidcLoginHandler godoc
+//
+//	@Summary		Starts a social login
+//	@Description	Redirects the browser to the identity provider using the authorization code flow with PKCE
+//	@Tags			authentication
+//	@Param			provider	path		string	true	"Provider name"
+//	@Success		302			{string}	string	"Redirect to the provider"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider}/login [get]
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundError(w, r, errors.New("unknown identity provider"))
+		return
+	}
+
+	authURL, err := app.startOIDCFlow(w, r, provider, 0)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// IdentityLinkRedirect is where to send the browser to link a provider.
+type IdentityLinkRedirect struct {
+	URL string `json:"url"`
+}
+
+// linkIdentityHandler godoc
+//
+//	@Summary		Starts linking a social login
+//	@Description	Returns the identity provider URL to send the browser to. Its callback links the provider account to the current user, who can then sign in with it.
+//	@Tags			users
+//	@Produce		json
+//	@Param			provider	path		string					true	"Provider name"
+//	@Success		200			{object}	IdentityLinkRedirect	"Provider URL"
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/identities/{provider} [post]
+func (app *application) linkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundError(w, r, errors.New("unknown identity provider"))
+		return
+	}
+
+	authURL, err := app.startOIDCFlow(w, r, provider, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, IdentityLinkRedirect{URL: authURL}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// startOIDCFlow remembers a new login state, binds it to the browser and
+// returns the provider URL to send the browser to. userID is set when a
+// signed-in user links the provider instead of signing in with it.
+func (app *application) startOIDCFlow(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID int64) (string, error) {
+	state := &store.LoginState{Provider: provider.Name(), UserID: userID}
+	for _, v := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		value, err := oidc.RandomString()
+		if err != nil {
+			return "", err
+		}
+		*v = value
+	}
+
+	ctx := r.Context()
+
+	authURL, err := provider.AuthCodeURL(ctx, state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		return "", err
+	}
+
+	if err := app.store.Identities.CreateLoginState(ctx, state, oidcStateExp); err != nil {
+		return "", err
+	}
+
+	// binds the flow to this browser so a callback cannot be replayed in another
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state.State,
+		Path:     "/v1/authentication/oidc",
+		MaxAge:   int(oidcStateExp.Seconds()),
+		HttpOnly: true,
+		Secure:   app.config.env == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return authURL, nil
+}
+
+// oidcCallbackHandler godoc
+//
+//	@Summary		Completes a social login
+//	@Description	Verifies the provider's ID token and issues a token pair to the user linked to it, creating one for a new email. An email that already has an account is refused until its owner links the provider. When the flow was started to link the provider, links it to that user instead.
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			provider	path		string				true	"Provider name"
+//	@Param			code		query		string				true	"Authorization code"
+//	@Param			state		query		string				true	"State"
+//	@Success		201			{object}	AuthTokens			"Tokens"
+//	@Success		202			{object}	TwoFactorChallenge	"Two-factor code required"
+//	@Success		204			{string}	string				"Identity linked"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error	"Account not active"
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider}/callback [get]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundError(w, r, errors.New("unknown identity provider"))
+		return
+	}
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		app.badRequestError(w, r, fmt.Errorf("identity provider error: %s", providerErr))
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		app.unAuthorizedError(w, r, errors.New("state mismatch"))
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   oidcStateCookie,
+		Path:   "/v1/authentication/oidc",
+		MaxAge: -1,
+	})
+
+	ctx := r.Context()
+
+	loginState, err := app.store.Identities.ConsumeLoginState(ctx, provider.Name(), state)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedError(w, r, errors.New("unknown or expired login state"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	claims, err := provider.Exchange(ctx, query.Get("code"), loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		app.unAuthorizedError(w, r, err)
+		return
+	}
+
+	// a signed-in user started the flow to link the provider to their account
+	if loginState.UserID != 0 {
+		identity := &store.Identity{
+			UserID:   loginState.UserID,
+			Provider: provider.Name(),
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}
+		if err := app.store.Identities.Link(ctx, identity); err != nil {
+			switch err {
+			case store.ErrDataConflict:
+				app.conflictError(w, r, errors.New("identity is linked already"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	user, err := app.userForIdentity(ctx, provider.Name(), claims)
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateEmail, errIdentityNotLinked:
+			app.conflictError(w, r, err)
+		case errUnverifiedEmail:
+			app.badRequestError(w, r, err)
+		case errInactiveAccount:
+			app.forbiddenError(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.Suspended() {
+		app.suspendedError(w, r, user.Suspension)
+		return
+	}
+
+	challenge, err := app.twoFactorChallenge(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if challenge != nil {
+		if err := app.writeJsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	tokens, err := app.createSession(r, user.ID, "oidc:"+provider.Name())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+var (
+	errUnverifiedEmail   = errors.New("identity provider did not return a verified email")
+	errInactiveAccount   = errors.New("account is not active")
+	errIdentityNotLinked = errors.New("an account with this email exists, sign in and link the provider to it")
+)
+
+// userForIdentity finds the user linked to the external identity, or creates
+// one for an unknown identity. An email that already has an account is never
+// enough to link it, since only its owner may do that from a signed-in
+// session.
+func (app *application) userForIdentity(ctx context.Context, provider string, claims *oidc.Claims) (*store.User, error) {
+	user, err := app.store.Identities.GetUser(ctx, provider, claims.Subject)
+	if err == nil {
+		if user.IsActive {
+			return user, nil
+		}
+
+		// signing in reactivates an account its owner deactivated
+		user, err = app.store.Users.GetDeactivatedByID(ctx, user.ID, app.config.account.reactivationWindow)
+		if err == store.ErrNotFound {
+			return nil, errInactiveAccount
+		}
+		return user, err
+	}
+	if err != store.ErrNotFound {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errUnverifiedEmail
+	}
+
+	_, err = app.store.Users.GetByEmail(ctx, claims.Email)
+	if err == nil {
+		return nil, errIdentityNotLinked
+	}
+	if err != store.ErrNotFound {
+		return nil, err
+	}
+
+	identity := &store.Identity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	// an inactive account with the email is refused as a duplicate
+	return app.createUserForIdentity(ctx, claims, identity)
+}
+
+func (app *application) createUserForIdentity(ctx context.Context, claims *oidc.Claims, identity *store.Identity) (*store.User, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameSanitizer.ReplaceAllString(strings.ToLower(base), "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if base == "" {
+		base = "gopher"
+	}
+
+	// the account can only be used through the provider until a password is set
+	unusable, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	username := base
+	for attempt := 0; attempt < 5; attempt++ {
+		user := &store.User{
+			Username: username,
+			Email:    claims.Email,
+			Role: store.Role{
+				Name: "user",
+			},
+		}
+
+		if err := user.Password.Set(unusable); err != nil {
+			return nil, err
+		}
+
+		err = app.store.Users.CreateWithIdentity(ctx, user, identity)
+		if err == nil {
+			return user, nil
+		}
+		if err != store.ErrDuplicateUsername {
+			return nil, err
+		}
+
+		username = fmt.Sprintf("%s%d", base, rand.Intn(10000))
+	}
+
+	return nil, err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ecetinerdem/gopherSocial/internal/oidc"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+type linkedIdentityStore struct {
+	store.MockIdentityStore
+	user *store.User
+}
+
+func (s *linkedIdentityStore) GetUser(context.Context, string, string) (*store.User, error) {
+	return s.user, nil
+}
+
+// inactiveEmailStore holds a deactivated user whose email a new identity
+// claims.
+type inactiveEmailStore struct {
+	deactivatedUserStore
+}
+
+func (s *inactiveEmailStore) CreateWithIdentity(context.Context, *store.User, *store.Identity) error {
+	return store.ErrDuplicateEmail
+}
+
+func TestUserForIdentity(t *testing.T) {
+	app := newTestApplication(t, config{})
+	claims := &oidc.Claims{Email: "gopher@example.com", EmailVerified: true}
+
+	t.Run("should return the linked user", func(t *testing.T) {
+		app.store.Identities = &linkedIdentityStore{user: &store.User{ID: 7, IsActive: true}}
+
+		user, err := app.userForIdentity(context.Background(), "google", claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != 7 {
+			t.Errorf("expected user 7 but got %d", user.ID)
+		}
+	})
+
+	t.Run("should refuse the linked user when inactive", func(t *testing.T) {
+		app.store.Identities = &linkedIdentityStore{user: &store.User{ID: 7}}
+
+		if _, err := app.userForIdentity(context.Background(), "google", claims); err != errInactiveAccount {
+			t.Errorf("expected %v but got %v", errInactiveAccount, err)
+		}
+	})
+
+	t.Run("should return the linked user who deactivated their account", func(t *testing.T) {
+		app.store.Identities = &linkedIdentityStore{user: &store.User{ID: 7}}
+		app.store.Users = &deactivatedUserStore{user: &store.User{ID: 7}}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		user, err := app.userForIdentity(context.Background(), "google", claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != 7 {
+			t.Errorf("expected user 7 but got %d", user.ID)
+		}
+	})
+	t.Run("should not link an account by its email", func(t *testing.T) {
+		app.store.Identities = &store.MockIdentityStore{}
+
+		if _, err := app.userForIdentity(context.Background(), "google", claims); err != errIdentityNotLinked {
+			t.Errorf("expected %v but got %v", errIdentityNotLinked, err)
+		}
+	})
+
+	t.Run("should not reactivate an account by its email", func(t *testing.T) {
+		users := &inactiveEmailStore{deactivatedUserStore{user: &store.User{ID: 7}}}
+		app.store.Identities = &store.MockIdentityStore{}
+		app.store.Users = users
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		if _, err := app.userForIdentity(context.Background(), "google", claims); err != store.ErrDuplicateEmail {
+			t.Errorf("expected %v but got %v", store.ErrDuplicateEmail, err)
+		}
+		if users.reactivated {
+			t.Error("expected the account to stay deactivated")
+		}
+	})
+}
+
+func TestLinkIdentity(t *testing.T) {
+	_, send := newSignedInTestApplication(t, config{})
+
+	t.Run("should refuse unknown providers", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodPost, "/v1/users/me/identities/nowhere", "").Code)
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// Permissions are granted to roles in the role_permissions table, so a new
+// role only needs rows there. Checks in code always name the permission and
+// never the role.
+const (
+	permPostUpdateAny = "post.update.any"
+	permPostDeleteAny = "post.delete.any"
+	permUserManage    = "user.manage"
+	permUserBan       = "user.ban"
+	permRoleManage    = "role.manage"
+	permAuditRead     = "audit.read"
+	permCommentHide   = "comment.hide"
+)
+
+// policy decides whether the authenticated user may go on with the request.
+type policy func(r *http.Request, user *store.User) (bool, error)
+
+// RequirePermission only lets users whose role has permission through.
+func (app *application) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return app.Authorize(app.permitted(permission))
+}
+
+// Authorize rejects requests the policy doesn't allow.
+func (app *application) Authorize(allow policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := allow(r, getUserFromCtx(r))
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenError(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *application) permitted(permission string) policy {
+	return func(r *http.Request, user *store.User) (bool, error) {
+		return app.hasPermission(r.Context(), user, permission)
+	}
+}
+
+// ownerOr allows the owner of the resource, and anyone else whose role has
+// permission.
+func (app *application) ownerOr(owner func(r *http.Request) int64, permission string) policy {
+	return func(r *http.Request, user *store.User) (bool, error) {
+		if owner(r) == user.ID {
+			return true, nil
+		}
+
+		return app.hasPermission(r.Context(), user, permission)
+	}
+}
+
+func (app *application) hasPermission(ctx context.Context, user *store.User, permission string) (bool, error) {
+	return app.store.Roles.HasPermission(ctx, user.Role.ID, permission)
+}
+
+func postOwner(r *http.Request) int64 {
+	return getPostFromCtx(r).UserID
+}
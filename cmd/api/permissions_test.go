@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+func TestRequirePermission(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	handler := app.RequirePermission(permPostDeleteAny)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := func(roleID int64) *http.Request {
+		req, err := http.NewRequest(http.MethodDelete, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user := &store.User{ID: 1, Role: store.Role{ID: roleID}}
+		return req.WithContext(context.WithValue(req.Context(), userCtx, user))
+	}
+
+	t.Run("should forbid roles without the permission", func(t *testing.T) {
+		rr := executeRequest(request(1), handler)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should allow roles with the permission", func(t *testing.T) {
+		// the mock store grants every permission to role 3
+		rr := executeRequest(request(3), handler)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
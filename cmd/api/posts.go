@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/ecetinerdem/gopherSocial/internal/store"
 	"github.com/go-chi/chi/v5"
@@ -15,20 +16,61 @@ type postKey string
 const postCtx postKey = "post"
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title     string     `json:"title" validate:"required,max=100"`
+	Content   string     `json:"content" validate:"required,max=1000"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"required,max=100"`
-	Content *string `json:"content" validate:"required,max=1000"`
+	Title     *string    `json:"title" validate:"required,max=100"`
+	Content   *string    `json:"content" validate:"required,max=1000"`
+	Status    *string    `json:"status" validate:"omitnil,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+var (
+	errPublishAtRequired   = errors.New("scheduled posts need a publish_at in the future")
+	errPublishAtUnexpected = errors.New("only scheduled posts take a publish_at")
+	errAlreadyPublished    = errors.New("published posts cannot be unpublished or rescheduled")
+)
+
+// postSchedule checks the status and publish time asked for a post. Without a
+// status a post is scheduled when it has a publish time and published now
+// otherwise.
+func postSchedule(status string, publishAt *time.Time) (string, *time.Time, error) {
+	if status == "" {
+		status = store.PostPublished
+		if publishAt != nil {
+			status = store.PostScheduled
+		}
+	}
+
+	switch status {
+	case store.PostScheduled:
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return "", nil, errPublishAtRequired
+		}
+		return status, publishAt, nil
+	case store.PostPublished:
+		if publishAt != nil {
+			return "", nil, errPublishAtUnexpected
+		}
+		now := time.Now()
+		return status, &now, nil
+	default:
+		if publishAt != nil {
+			return "", nil, errPublishAtUnexpected
+		}
+		return status, nil, nil
+	}
 }
 
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post, published right away unless it is saved as a draft or scheduled with a publish_at
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -53,17 +95,25 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	status, publishAt, err := postSchedule(payload.Status, payload.PublishAt)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
 	user := getUserFromCtx(r)
 
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+		Title:     payload.Title,
+		Content:   payload.Content,
+		Tags:      payload.Tags,
+		UserID:    user.ID,
+		Status:    status,
+		PublishAt: publishAt,
 	}
 
 	ctx := r.Context()
-	err := app.store.Posts.Create(ctx, post)
+	err = app.store.Posts.Create(ctx, post)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -92,8 +142,14 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post := getPostFromCtx(r)
+	ctx := r.Context()
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	if ok, err := app.canViewPost(ctx, getUserFromCtx(r), post); err != nil || !ok {
+		app.hiddenPostError(w, r, err)
+		return
+	}
+
+	comments, err := app.store.Comments.GetByPostID(ctx, post.ID, getUserFromCtx(r).ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -110,7 +166,7 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID. Its author can also reschedule drafts and scheduled posts, turn them back into drafts or publish them.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -119,6 +175,7 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 //	@Success		200		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error	"Only the author can change the status"
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
@@ -145,6 +202,36 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Title = *payload.Title
 	}
 
+	if payload.Status != nil || payload.PublishAt != nil {
+		// moderators may edit a post, but only its author decides when it goes out
+		if post.UserID != getUserFromCtx(r).ID {
+			app.forbiddenError(w, r)
+			return
+		}
+
+		if post.Status == store.PostPublished {
+			app.badRequestError(w, r, errAlreadyPublished)
+			return
+		}
+
+		status := ""
+		if payload.Status != nil {
+			status = *payload.Status
+		}
+
+		publishAt := payload.PublishAt
+		if publishAt == nil && status == store.PostScheduled {
+			publishAt = post.PublishAt
+		}
+
+		var err error
+		post.Status, post.PublishAt, err = postSchedule(status, publishAt)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
 	err := app.store.Posts.Update(r.Context(), post)
 	if err != nil {
 		switch {
@@ -201,6 +288,17 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// anyone but the author got here through the post.delete.any permission
+	if user, post := getUserFromCtx(r), getPostFromCtx(r); user.ID != post.UserID {
+		app.audit(r, &store.AuditEvent{
+			Event:      store.AuditPostModerated,
+			ActorID:    user.ID,
+			TargetType: "post",
+			TargetID:   post.ID,
+			Metadata:   map[string]any{"author_id": post.UserID, "title": post.Title},
+		})
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 
 }
@@ -240,3 +338,48 @@ func getPostFromCtx(r *http.Request) *store.Post {
 
 	return post
 }
+
+// canViewPost tells whether viewer may see post. Drafts and scheduled posts
+// are only shown to their author.
+func (app *application) canViewPost(ctx context.Context, viewer *store.User, post *store.Post) (bool, error) {
+	if post.Status != store.PostPublished {
+		return viewer.ID == post.UserID, nil
+	}
+
+	return app.canViewPostsOf(ctx, viewer, post.UserID)
+}
+
+// canViewPostsOf tells whether viewer may see the posts of author: anyone
+// may see those of a public account, only approved followers those of a
+// private one, and nobody when either blocked the other.
+func (app *application) canViewPostsOf(ctx context.Context, viewer *store.User, authorID int64) (bool, error) {
+	if viewer.ID == authorID {
+		return true, nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, authorID)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	author, err := app.getVisibleUser(ctx, authorID)
+	if err != nil {
+		return false, err
+	}
+	if !author.Private {
+		return true, nil
+	}
+
+	return app.store.Followers.IsFollowing(ctx, viewer.ID, authorID)
+}
+
+// hiddenPostError answers for a post the user may not see, or whose author
+// could not be checked, as if the post did not exist.
+func (app *application) hiddenPostError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case nil, store.ErrNotFound:
+		app.notFoundError(w, r, store.ErrNotFound)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+type draftStore struct {
+	store.MockPostStore
+}
+
+func (s *draftStore) GetByID(ctx context.Context, postID int64) (*store.Post, error) {
+	return &store.Post{ID: postID, UserID: 7, Status: store.PostDraft}, nil
+}
+
+func TestDrafts(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	t.Run("should save drafts and scheduled posts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, send(http.MethodPost, "/v1/posts", `{"title":"t","content":"c","status":"draft"}`).Code)
+		checkResponseCode(t, http.StatusCreated, send(http.MethodPost, "/v1/posts", `{"title":"t","content":"c","publish_at":"2999-01-01T00:00:00Z"}`).Code)
+	})
+
+	t.Run("should reject scheduling in the past", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, send(http.MethodPost, "/v1/posts", `{"title":"t","content":"c","status":"scheduled"}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, send(http.MethodPost, "/v1/posts", `{"title":"t","content":"c","publish_at":"2000-01-01T00:00:00Z"}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, send(http.MethodPost, "/v1/posts", `{"title":"t","content":"c","status":"draft","publish_at":"2999-01-01T00:00:00Z"}`).Code)
+	})
+
+	t.Run("should list drafts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/users/me/drafts", "").Code)
+		checkResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/users/me/drafts?limit=0", "").Code)
+	})
+
+	t.Run("should hide drafts of others", func(t *testing.T) {
+		app.store.Posts = &draftStore{}
+
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodGet, "/v1/posts/1", "").Code)
+	})
+
+	t.Run("should let moderators edit but not publish drafts of others", func(t *testing.T) {
+		app.store.Posts = &draftStore{}
+		app.store.Users = &adminUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		checkResponseCode(t, http.StatusOK, send(http.MethodPatch, "/v1/posts/1", `{"title":"t","content":"edited"}`).Code)
+		checkResponseCode(t, http.StatusForbidden, send(http.MethodPatch, "/v1/posts/1", `{"title":"t","content":"c","status":"published"}`).Code)
+		checkResponseCode(t, http.StatusForbidden, send(http.MethodPatch, "/v1/posts/1", `{"title":"t","content":"c","publish_at":"2999-01-01T00:00:00Z"}`).Code)
+	})
+}
+
+type othersPostStore struct {
+	store.MockPostStore
+}
+
+func (s *othersPostStore) GetByID(ctx context.Context, postID int64) (*store.Post, error) {
+	return &store.Post{ID: postID, UserID: 7, Title: "Hello", Status: store.PostPublished}, nil
+}
+
+func TestPostPrivacy(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	followers := &fakeFollowerStore{}
+	blocks := &fakeBlockStore{}
+	app.store.Posts = &othersPostStore{}
+	app.store.Followers = followers
+	app.store.Blocks = blocks
+
+	t.Run("should show posts of public accounts", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/posts/1", "")
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var post store.Post
+		readData(t, rr, &post)
+		if post.ID != 1 || post.Title != "Hello" {
+			t.Errorf("expected post 1 but got %+v", post)
+		}
+	})
+
+	t.Run("should show posts of private accounts to followers only", func(t *testing.T) {
+		app.store.Users = &privateUserStore{private: 7}
+
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodGet, "/v1/posts/1", "").Code)
+
+		followers.following = true
+		checkResponseCode(t, http.StatusCreated, send(http.MethodGet, "/v1/posts/1", "").Code)
+	})
+
+	t.Run("should hide posts across a block", func(t *testing.T) {
+		blocks.blocked = true
+
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodGet, "/v1/posts/1", "").Code)
+	})
+}
+
+type recordingCommentStore struct {
+	store.MockCommentStore
+	created []*store.Comment
+	hidden  []*store.Comment
+}
+
+func (s *recordingCommentStore) Create(ctx context.Context, comment *store.Comment) error {
+	s.created = append(s.created, comment)
+	return nil
+}
+
+func (s *recordingCommentStore) Hide(ctx context.Context, comment *store.Comment) error {
+	if comment.ID != 5 {
+		return store.ErrNotFound
+	}
+	comment.UserID = 7
+	s.hidden = append(s.hidden, comment)
+	return nil
+}
+
+func TestCreateComment(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	t.Run("should attribute the comment to the commenter", func(t *testing.T) {
+		comments := &recordingCommentStore{}
+		app.store.Comments = comments
+		app.store.Posts = &othersPostStore{}
+
+		checkResponseCode(t, http.StatusOK, send(http.MethodPost, "/v1/posts/1/comments", `{"content":"nice"}`).Code)
+
+		if len(comments.created) != 1 || comments.created[0].UserID != 1 || comments.created[0].PostID != 1 {
+			t.Errorf("expected a comment by user 1 on post 1 but got %+v", comments.created)
+		}
+	})
+}
+
+func TestHideComment(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	comments := &recordingCommentStore{}
+	app.store.Comments = comments
+	app.store.Posts = &othersPostStore{}
+	app.auditEvents = make(chan *store.AuditEvent, 1)
+
+	t.Run("should not let users hide comments", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, send(http.MethodDelete, "/v1/posts/1/comments/5", "").Code)
+
+		if len(comments.hidden) != 0 {
+			t.Errorf("expected no comment to be hidden but got %+v", comments.hidden)
+		}
+	})
+
+	t.Run("should let moderators hide comments", func(t *testing.T) {
+		app.store.Users = &adminUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		checkResponseCode(t, http.StatusNoContent, send(http.MethodDelete, "/v1/posts/1/comments/5", "").Code)
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodDelete, "/v1/posts/1/comments/6", "").Code)
+
+		if len(comments.hidden) != 1 || comments.hidden[0].PostID != 1 {
+			t.Errorf("expected comment 5 on post 1 to be hidden but got %+v", comments.hidden)
+		}
+
+		e := <-app.auditEvents
+		if e.Event != store.AuditCommentHidden || e.ActorID != 1 || e.TargetID != 5 || e.Metadata["author_id"] != int64(7) {
+			t.Errorf("expected the hidden comment in the audit log but got %+v", e)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// searchUsersHandler godoc
+//
+//	@Summary		Searches users
+//	@Description	Finds users whose username or display name resembles the query, tolerating typos. Best matches come first.
+//	@Tags			users
+//	@Produce		json
+//	@Param			q		query		string	true	"Search text"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.UserSummary
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/search [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.UserSearchQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.Search(r.Context(), getUserFromCtx(r).ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// autocompleteUsersHandler godoc
+//
+//	@Summary		Completes usernames
+//	@Description	Lists users whose username starts with the query, for @mention pickers. Users the caller follows come first.
+//	@Tags			users
+//	@Produce		json
+//	@Param			q		query		string	true	"Start of the username, with or without the @"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.UserSummary
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/autocomplete [get]
+func (app *application) autocompleteUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.AutocompleteQuery{
+		Limit: 8,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.Autocomplete(r.Context(), getUserFromCtx(r).ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
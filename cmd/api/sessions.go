@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const maxUserAgentLength = 512
+
+type SessionWithCurrent struct {
+	*store.Session
+	Current bool `json:"current"`
+}
+
+// listSessionsHandler godoc
+//
+//	@Summary		Lists sessions
+//	@Description	Lists the devices the current user is signed in on, marking the one making the request
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]SessionWithCurrent
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	currentID := getSessionIDFromCtx(r)
+
+	sessions, err := app.store.Sessions.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := make([]SessionWithCurrent, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionWithCurrent{
+			Session: session,
+			Current: session.ID == currentID,
+		})
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// revokeSessionHandler godoc
+//
+//	@Summary		Revokes a session
+//	@Description	Signs the current user out on one device
+//	@Tags			users
+//	@Produce		json
+//	@Param			sessionID	path		string	true	"Session ID"
+//	@Success		204			{string}	string	"Session revoked"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	if err := uuid.Validate(sessionID); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	err := app.store.Sessions.Revoke(r.Context(), user.ID, sessionID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeOtherSessionsHandler godoc
+//
+//	@Summary		Revokes all other sessions
+//	@Description	Signs the current user out on every device except the one making the request
+//	@Tags			users
+//	@Produce		json
+//	@Success		204	{string}	string	"Sessions revoked"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [delete]
+func (app *application) revokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.store.Sessions.RevokeOthers(r.Context(), user.ID, getSessionIDFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sweepSessions deletes the sessions that were revoked or expired longer ago
+// than the retention period.
+func (app *application) sweepSessions(ctx context.Context) {
+	deleted, err := app.store.Sessions.DeleteEnded(ctx, app.config.auth.sessions.retention)
+	if err != nil {
+		app.logger.Errorw("error deleting ended sessions", "error", err)
+		return
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("swept sessions", "deleted", deleted)
+	}
+}
+
+// clientIP returns the address of the client. RealIP has already replaced
+// RemoteAddr with the forwarded address when there is one.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+
+	return ua
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+// getSuggestionsHandler godoc
+//
+//	@Summary		Suggests accounts to follow
+//	@Description	Lists accounts the current user might want to follow, best first, with the reasons each was picked. Suggestions are refreshed in the background.
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Success		200		{object}	[]store.Suggestion
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/suggestions [get]
+func (app *application) getSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.SuggestionQuery{
+		Limit: 10,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	suggestions, err := app.store.Suggestions.Get(ctx, user.ID, q.Limit)
+	if err == store.ErrNotFound {
+		// new accounts ask before the batch got to them; compute theirs
+		// once now and leave refreshing to the batch
+		if err = app.store.Suggestions.Compute(ctx, user.ID); err == nil {
+			suggestions, err = app.store.Suggestions.Get(ctx, user.ID, q.Limit)
+		}
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// refreshSuggestions recomputes the suggestions of a batch of users whose
+// suggestions are missing or stale.
+func (app *application) refreshSuggestions(ctx context.Context) {
+	cfg := app.config.suggestions
+
+	ids, err := app.store.Suggestions.ClaimStale(ctx, cfg.maxAge, cfg.batchSize)
+	if err != nil {
+		app.logger.Errorw("error claiming users for suggestions", "error", err)
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := app.store.Suggestions.Compute(ctx, id); err != nil {
+			app.logger.Errorw("error computing suggestions", "user_id", id, "error", err)
+		}
+	}
+
+	if len(ids) > 0 {
+		app.logger.Infow("refreshed follow suggestions", "users", len(ids))
+	}
+}
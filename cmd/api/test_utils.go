@@ -1,13 +1,16 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/ecetinerdem/gopherSocial/internal/auth"
 	"github.com/ecetinerdem/gopherSocial/internal/store"
 	"github.com/ecetinerdem/gopherSocial/internal/store/cache"
+	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 )
 
@@ -23,10 +26,64 @@ func newTestApplication(t *testing.T, cfg config) *application {
 		store:         mockStore,
 		cacheStorage:  mockCache,
 		authenticator: testAuthenticator,
+		imageSlots:    make(chan struct{}, 1),
 		config:        cfg,
 	}
 }
 
+// requestFunc sends a request to the routes of a test application and
+// records the response.
+type requestFunc func(method, path, body string) *httptest.ResponseRecorder
+
+// newSignedInTestApplication returns a test application whose user cache is
+// enabled but always misses, and a requestFunc calling its routes as the user
+// of the mock token.
+func newSignedInTestApplication(t *testing.T, cfg config) (*application, requestFunc) {
+	t.Helper()
+
+	cfg.redisCfg.enabled = true
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockCacheStore := app.cacheStorage.Users.(*cache.MockUserStore)
+	mockCacheStore.On("Get", mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything).Return(nil)
+	mockCacheStore.On("Delete", mock.Anything)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		return &rr
+	}
+
+	return app, send
+}
+
+// readData decodes the data envelope of a JSON response into v.
+func readData(t *testing.T, rr *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	envelope := struct {
+		Data any `json:"data"`
+	}{Data: v}
+
+	if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func executeRequest(r *http.Request, mux http.Handler) httptest.ResponseRecorder {
 
 	recorder := httptest.NewRecorder()
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/auth"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+const recoveryCodeCount = 10
+
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type ConfirmTwoFactorPayload struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"`
+}
+
+type VerifyTwoFactorPayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required,max=255"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode   string `json:"recovery_code" validate:"required_without=Code,omitempty,max=20"`
+}
+
+// enrollTwoFactorHandler godoc
+//
+//	@Summary		Starts two-factor enrollment
+//	@Description	Generates a TOTP secret and the provisioning URI to show as a QR code. Enrollment is pending until confirmed with a code.
+//	@Tags			users
+//	@Produce		json
+//	@Success		201	{object}	TwoFactorEnrollment
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"Two-factor authentication already enabled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa [post]
+func (app *application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.TwoFactor.Enroll(r.Context(), user.ID, secret)
+	if err != nil {
+		switch err {
+		case store.ErrDataConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := &TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(secret, app.config.auth.twoFactor.issuer, user.Email),
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusCreated, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// confirmTwoFactorHandler godoc
+//
+//	@Summary		Confirms two-factor enrollment
+//	@Description	Enables two-factor authentication with a code from the authenticator app and returns one-time recovery codes. The codes are only shown once.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ConfirmTwoFactorPayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Two-factor authentication already enabled"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa/confirm [post]
+func (app *application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ConfirmTwoFactorPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	tf, err := app.store.TwoFactor.Get(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestError(w, r, errors.New("two-factor enrollment not started"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if tf.Enabled() {
+		app.conflictError(w, r, errors.New("two-factor authentication already enabled"))
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(tf.Secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestError(w, r, errors.New("invalid code"))
+		return
+	}
+
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.TwoFactor.Confirm(ctx, user.ID, step, codes)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestError(w, r, errors.New("invalid code"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusOK, &RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// verifyTwoFactorHandler godoc
+//
+//	@Summary		Completes a two-factor login
+//	@Description	Exchanges the challenge returned by /authentication/token and a TOTP or recovery code for a token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		VerifyTwoFactorPayload	true	"Challenge and code"
+//	@Success		201		{object}	AuthTokens				"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed attempts"
+//	@Failure		500		{object}	error
+//	@Router			/authentication/2fa [post]
+func (app *application) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyTwoFactorPayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.TwoFactor.AttemptChallenge(ctx, payload.ChallengeToken)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	ip := clientIP(r)
+	subjects := secondFactorSubjects(user.ID, ip)
+
+	retryAfter, err := app.loginRetryAfter(ctx, subjects)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if retryAfter > 0 {
+		app.audit(r, &store.AuditEvent{
+			Event:      store.AuditLoginFailed,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Metadata:   map[string]any{"reason": "throttled"},
+		})
+		app.rateLimitExceedResponse(w, r, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return
+	}
+
+	if err := app.checkSecondFactor(ctx, user.ID, payload); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.audit(r, &store.AuditEvent{
+				Event:      store.AuditLoginFailed,
+				TargetType: "user",
+				TargetID:   user.ID,
+				Metadata:   map[string]any{"reason": "invalid_two_factor_code"},
+			})
+
+			if err := app.recordLoginFailure(ctx, subjects, ip, user); err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			app.unAuthorizedError(w, r, errors.New("invalid two-factor code"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.TwoFactor.DeleteChallenge(ctx, payload.ChallengeToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.resetLoginFailures(ctx, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.createSession(r, user.ID, "two_factor")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.writeJsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code.
+// Any code that does not verify is reported as store.ErrNotFound.
+func (app *application) checkSecondFactor(ctx context.Context, userID int64, payload VerifyTwoFactorPayload) error {
+	if payload.RecoveryCode != "" {
+		return app.store.TwoFactor.UseRecoveryCode(ctx, userID, payload.RecoveryCode)
+	}
+
+	tf, err := app.store.TwoFactor.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	step, ok := auth.ValidateTOTP(tf.Secret, payload.Code, time.Now())
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	return app.store.TwoFactor.UseStep(ctx, userID, step)
+}
+
+// twoFactorChallenge returns a challenge when the user has two-factor
+// authentication enabled, or nil when a password is enough.
+func (app *application) twoFactorChallenge(ctx context.Context, userID int64) (*TwoFactorChallenge, error) {
+	tf, err := app.store.TwoFactor.Get(ctx, userID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !tf.Enabled() {
+		return nil, nil
+	}
+
+	token, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.store.TwoFactor.CreateChallenge(ctx, userID, token, app.config.auth.twoFactor.challengeExp); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int64(app.config.auth.twoFactor.challengeExp.Seconds()),
+	}, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/auth"
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+type enrolledTwoFactorStore struct {
+	store.MockTwoFactorStore
+	confirmed     bool
+	recoveryCodes map[string]bool
+}
+
+func (s *enrolledTwoFactorStore) Get(ctx context.Context, userID int64) (*store.TwoFactor, error) {
+	return &store.TwoFactor{
+		UserID:      userID,
+		Secret:      testTOTPSecret,
+		ConfirmedAt: sql.NullTime{Time: time.Now(), Valid: s.confirmed},
+	}, nil
+}
+
+func (s *enrolledTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	if !s.recoveryCodes[code] {
+		return store.ErrNotFound
+	}
+	delete(s.recoveryCodes, code)
+	return nil
+}
+
+// countingLoginFailureStore remembers the subjects failures were recorded
+// against and reset for, and reports lockedScope as locked.
+type countingLoginFailureStore struct {
+	store.MockLoginFailureStore
+	lockedScope string
+	recorded    []string
+	reset       []string
+}
+
+func (s *countingLoginFailureStore) Get(ctx context.Context, scope, subject string) (*store.LoginFailure, error) {
+	if scope != s.lockedScope {
+		return nil, store.ErrNotFound
+	}
+	until := time.Now().Add(time.Minute)
+	return &store.LoginFailure{Scope: scope, Subject: subject, Failures: 10, LastFailureAt: time.Now(), LockedUntil: &until}, nil
+}
+
+func (s *countingLoginFailureStore) Record(ctx context.Context, scope, subject string, window time.Duration, lockAfter int, lockFor time.Duration) (*store.LoginFailure, error) {
+	s.recorded = append(s.recorded, scope+":"+subject)
+	return s.MockLoginFailureStore.Record(ctx, scope, subject, window, lockAfter, lockFor)
+}
+
+func (s *countingLoginFailureStore) Reset(ctx context.Context, scope, subject string) error {
+	s.reset = append(s.reset, scope+":"+subject)
+	return nil
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	post := func(path, body string) *http.Response {
+		return send(http.MethodPost, path, body).Result()
+	}
+
+	t.Run("should return a secret and provisioning URI", func(t *testing.T) {
+		res := post("/v1/users/me/2fa", "")
+		checkResponseCode(t, http.StatusCreated, res.StatusCode)
+
+		var body struct {
+			Data TwoFactorEnrollment `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if body.Data.Secret == "" || !strings.Contains(body.Data.ProvisioningURI, "secret="+body.Data.Secret) {
+			t.Errorf("expected a secret in the provisioning URI but got %+v", body.Data)
+		}
+	})
+
+	t.Run("should confirm with a valid code and return recovery codes", func(t *testing.T) {
+		app.store.TwoFactor = &enrolledTwoFactorStore{}
+
+		checkResponseCode(t, http.StatusBadRequest, post("/v1/users/me/2fa/confirm", `{"code":"000000"}`).StatusCode)
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := post("/v1/users/me/2fa/confirm", `{"code":"`+code+`"}`)
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Data RecoveryCodes `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data.RecoveryCodes) != recoveryCodeCount {
+			t.Errorf("expected %d recovery codes but got %d", recoveryCodeCount, len(body.Data.RecoveryCodes))
+		}
+	})
+
+	t.Run("should refuse to confirm twice", func(t *testing.T) {
+		app.store.TwoFactor = &enrolledTwoFactorStore{confirmed: true}
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusConflict, post("/v1/users/me/2fa/confirm", `{"code":"`+code+`"}`).StatusCode)
+	})
+}
+
+func TestVerifyTwoFactor(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{
+			lockout: lockoutConfig{accountThreshold: 5, ipThreshold: 50, duration: time.Minute},
+		},
+	})
+	mux := app.mount()
+
+	verify := func(body string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/2fa", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		return rr.Code
+	}
+
+	t.Run("should issue tokens for a valid code and clear the counters", func(t *testing.T) {
+		failures := &countingLoginFailureStore{}
+		app.store.LoginFailures = failures
+		app.store.TwoFactor = &enrolledTwoFactorStore{confirmed: true}
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusCreated, verify(`{"challenge_token":"t","code":"`+code+`"}`))
+
+		if strings.Join(failures.reset, ",") != "account:,two_factor:1" {
+			t.Errorf("expected the account and two-factor counters to be reset but got %v", failures.reset)
+		}
+	})
+
+	t.Run("should count wrong codes against the user", func(t *testing.T) {
+		failures := &countingLoginFailureStore{}
+		app.store.LoginFailures = failures
+		app.store.TwoFactor = &enrolledTwoFactorStore{confirmed: true}
+
+		checkResponseCode(t, http.StatusUnauthorized, verify(`{"challenge_token":"t","code":"000000"}`))
+
+		found := false
+		for _, subject := range failures.recorded {
+			found = found || subject == "two_factor:1"
+		}
+		if !found {
+			t.Errorf("expected a failure recorded against the user but got %v", failures.recorded)
+		}
+		if len(failures.reset) != 0 {
+			t.Errorf("expected no counters reset but got %v", failures.reset)
+		}
+	})
+
+	t.Run("should accept a recovery code once", func(t *testing.T) {
+		app.store.LoginFailures = &countingLoginFailureStore{}
+		app.store.TwoFactor = &enrolledTwoFactorStore{confirmed: true, recoveryCodes: map[string]bool{"abcde-fghij": true}}
+
+		checkResponseCode(t, http.StatusCreated, verify(`{"challenge_token":"t","recovery_code":"abcde-fghij"}`))
+		checkResponseCode(t, http.StatusUnauthorized, verify(`{"challenge_token":"t","recovery_code":"abcde-fghij"}`))
+	})
+
+	t.Run("should refuse codes while the user is locked out", func(t *testing.T) {
+		app.store.LoginFailures = &countingLoginFailureStore{lockedScope: store.LoginScopeTwoFactor}
+		app.store.TwoFactor = &enrolledTwoFactorStore{confirmed: true}
+
+		code, err := auth.TOTPCode(testTOTPSecret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusTooManyRequests, verify(`{"challenge_token":"t","code":"`+code+`"}`))
+	})
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/ecetinerdem/gopherSocial/internal/store"
 	"github.com/go-chi/chi/v5"
@@ -21,7 +23,7 @@ const userCtx userKey = "user"
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"User ID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	UserProfile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
@@ -37,7 +39,7 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	user, err := app.getUser(ctx, userID)
+	user, err := app.getVisibleUser(ctx, userID)
 
 	if err != nil {
 		switch err {
@@ -50,23 +52,143 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = app.writeJsonResponse(w, http.StatusOK, user)
+	// counts change with every follow, so they are read fresh rather than
+	// cached with the user
+	counts, err := app.store.Followers.GetCounts(ctx, userID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+
+	err = app.writeJsonResponse(w, http.StatusOK, &UserProfile{User: user, FollowCounts: *counts})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UserProfile is a user as shown to others, with follower counts.
+type UserProfile struct {
+	*store.User
+	store.FollowCounts
+}
+
+type UpdateProfilePayload struct {
+	Username    *string `json:"username" validate:"omitnil,min=1,max=100"`
+	DisplayName *string `json:"display_name" validate:"omitnil,max=100"`
+	Bio         *string `json:"bio" validate:"omitnil,max=500"`
+	Website     *string `json:"website" validate:"omitnil,max=255"`
+	Location    *string `json:"location" validate:"omitnil,max=100"`
+	Pronouns    *string `json:"pronouns" validate:"omitnil,max=50"`
+	Private     *bool   `json:"is_private"`
+}
+
+// UpdateProfile godoc
+//
+//	@Summary		Updates the current user's profile
+//	@Description	Changes the username and profile fields that are present in the payload. An empty string clears an optional field.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateProfilePayload	true	"Profile fields"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Username taken"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProfilePayload
+
+	if err := readJson(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// an empty website clears it, anything else has to be a link
+	if payload.Website != nil {
+		if website := strings.TrimSpace(*payload.Website); website != "" {
+			if err := Validate.Var(website, "http_url"); err != nil {
+				app.badRequestError(w, r, err)
+				return
+			}
+		}
+	}
+
+	ctx := r.Context()
+
+	// the cached copy may be stale, so start from the database
+	user, err := app.store.Users.GetUserByID(ctx, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if payload.Username != nil {
+		user.Username = strings.TrimSpace(*payload.Username)
+	}
+	if payload.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*payload.DisplayName)
+	}
+	if payload.Bio != nil {
+		user.Bio = strings.TrimSpace(*payload.Bio)
+	}
+	if payload.Website != nil {
+		user.Website = strings.TrimSpace(*payload.Website)
+	}
+	if payload.Location != nil {
+		user.Location = strings.TrimSpace(*payload.Location)
+	}
+	if payload.Pronouns != nil {
+		user.Pronouns = strings.TrimSpace(*payload.Pronouns)
+	}
+	if payload.Private != nil {
+		user.Private = *payload.Private
+	}
+
+	if user.Username == "" {
+		app.badRequestError(w, r, errors.New("username cannot be blank"))
+		return
+	}
+
+	if err := app.store.Users.UpdateProfile(ctx, user); err != nil {
+		switch err {
+		case store.ErrDuplicateUsername:
+			app.conflictError(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	if err := app.writeJsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 }
 
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Follows a user by ID
+//	@Description	Follows a user by ID. Following a private account sends a request its owner has to approve.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
 //	@Success		204		{string}	string	"User followed"
+//	@Success		202		{string}	string	"Follow requested"
 //	@Failure		400		{object}	error	"User payload missing"
+//	@Failure		403		{object}	error	"Blocked"
 //	@Failure		404		{object}	error	"User not found"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
@@ -80,6 +202,43 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
+
+	followedUser, err := app.getVisibleUser(ctx, followedUserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, followerUser.ID, followedUserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if blocked {
+		app.forbiddenError(w, r)
+		return
+	}
+
+	if followedUser.Private {
+		if err := app.store.Followers.RequestFollow(ctx, followerUser.ID, followedUserID); err != nil {
+			switch err {
+			case store.ErrDataConflict:
+				app.conflictError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
 	err = app.store.Followers.Follow(ctx, followerUser.ID, followedUserID)
 	if err != nil {
 		switch err {
@@ -102,7 +261,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser gdoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user by ID
+//	@Description	Unfollow a user by ID, or withdraw a pending request to follow them
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -150,7 +309,7 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
 
-	err := app.store.Users.Activate(r.Context(), token)
+	user, err := app.store.Users.Activate(r.Context(), token)
 
 	if err != nil {
 		switch err {
@@ -162,6 +321,13 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.audit(r, &store.AuditEvent{
+		Event:      store.AuditUserActivated,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
 	if err := app.writeJsonResponse(w, http.StatusOK, ""); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ecetinerdem/gopherSocial/internal/store"
 	"github.com/ecetinerdem/gopherSocial/internal/store/cache"
 	"github.com/stretchr/testify/mock"
 )
@@ -105,3 +109,398 @@ func TestGetUser(t *testing.T) {
 		mockCacheStore.Calls = nil // Reset mock expectations
 	})
 }
+
+func TestUpdateProfile(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	mockCacheStore := app.cacheStorage.Users.(*cache.MockUserStore)
+
+	t.Run("should reject invalid websites", func(t *testing.T) {
+		rr := send(http.MethodPatch, "/v1/users/me", `{"website":"javascript:alert(1)"}`)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+
+		mockCacheStore.AssertNotCalled(t, "Delete", mock.Anything)
+	})
+
+	t.Run("should update the profile and invalidate the cached user", func(t *testing.T) {
+		rr := send(http.MethodPatch, "/v1/users/me", `{"username":"gopher","bio":"Hello","website":"https://go.dev"}`)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var user store.User
+		readData(t, rr, &user)
+		if user.Username != "gopher" || user.Bio != "Hello" || user.Website != "https://go.dev" {
+			t.Errorf("expected the updated profile but got %+v", user)
+		}
+
+		mockCacheStore.AssertCalled(t, "Delete", mock.Anything)
+	})
+
+	t.Run("should clear the website", func(t *testing.T) {
+		app.store.Users = &websiteUserStore{}
+
+		rr := send(http.MethodPatch, "/v1/users/me", `{"website":""}`)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var user store.User
+		readData(t, rr, &user)
+		if user.Website != "" {
+			t.Errorf("expected no website but got %s", user.Website)
+		}
+	})
+}
+
+// websiteUserStore serves users who have a website.
+type websiteUserStore struct {
+	store.MockUserStore
+}
+
+func (s *websiteUserStore) GetUserByID(ctx context.Context, userID int64) (*store.User, error) {
+	return &store.User{ID: userID, Username: "gopher", Profile: store.Profile{Website: "https://go.dev"}}, nil
+}
+
+// goneImageStore finds no active account to set images on, as when the user
+// is deactivated in the middle of a request.
+type goneImageStore struct {
+	store.MockUserStore
+}
+
+func (s *goneImageStore) SetImage(context.Context, int64, string, map[string]string, []string) ([]string, error) {
+	return nil, store.ErrNotFound
+}
+
+func TestUploadImage(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{
+		media: mediaConfig{
+			maxUploadBytes: 1024,
+			maxPixels:      1_000_000,
+		},
+	})
+
+	t.Run("should reject files that are not images", func(t *testing.T) {
+		rr := send(http.MethodPut, "/v1/users/me/avatar", `<svg xmlns="http://www.w3.org/2000/svg"></svg>`)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should reject files over the size limit", func(t *testing.T) {
+		rr := send(http.MethodPut, "/v1/users/me/avatar", strings.Repeat("a", 2048))
+		checkResponseCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+	})
+
+	t.Run("should not find the image of an account that is gone", func(t *testing.T) {
+		app.store.Users = &goneImageStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodDelete, "/v1/users/me/avatar", "").Code)
+	})
+}
+
+// fakeFollowerStore serves one page of each follow list and remembers what it
+// was asked for.
+type fakeFollowerStore struct {
+	store.MockFollowerStore
+	following bool
+	viewerID  int64
+	query     store.FollowListQuery
+	followed  []int64
+	requested []int64
+}
+
+func (s *fakeFollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, q store.FollowListQuery) ([]*store.FollowListEntry, string, error) {
+	s.viewerID, s.query = viewerID, q
+	return []*store.FollowListEntry{{ID: 7, Username: "gopher", FollowedByViewer: true}}, "next-page", nil
+}
+
+func (s *fakeFollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, q store.FollowListQuery) ([]*store.FollowListEntry, string, error) {
+	s.viewerID, s.query = viewerID, q
+	return []*store.FollowListEntry{{ID: 8, Username: "ferris"}}, "", nil
+}
+
+func (s *fakeFollowerStore) GetFollowRequests(ctx context.Context, userID int64, q store.FollowListQuery) ([]*store.FollowListEntry, string, error) {
+	return []*store.FollowListEntry{{ID: 9, Username: "newcomer"}}, "", nil
+}
+
+func (s *fakeFollowerStore) GetCounts(context.Context, int64) (*store.FollowCounts, error) {
+	return &store.FollowCounts{Followers: 3, Following: 5}, nil
+}
+
+func (s *fakeFollowerStore) IsFollowing(context.Context, int64, int64) (bool, error) {
+	return s.following, nil
+}
+
+func (s *fakeFollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
+	s.followed = append(s.followed, userID)
+	return nil
+}
+
+func (s *fakeFollowerStore) RequestFollow(ctx context.Context, followerID, userID int64) error {
+	s.requested = append(s.requested, userID)
+	return nil
+}
+
+// privateUserStore serves users, of whom one has a private account.
+type privateUserStore struct {
+	store.MockUserStore
+	private int64
+}
+
+func (s *privateUserStore) GetUserByID(ctx context.Context, userID int64) (*store.User, error) {
+	user := &store.User{ID: userID}
+	user.Private = userID == s.private
+	return user, nil
+}
+
+func TestFollowLists(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{apiURL: "localhost:8080"})
+
+	followers := &fakeFollowerStore{}
+	app.store.Followers = followers
+
+	t.Run("should link to the next page of followers", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/42/followers?limit=1", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		want := `<http://localhost:8080/v1/users/42/followers?cursor=next-page&limit=1>; rel="next"`
+		if got := rr.Header().Get("Link"); got != want {
+			t.Errorf("expected the Link header %s but got %s", want, got)
+		}
+
+		var entries []store.FollowListEntry
+		readData(t, rr, &entries)
+		if len(entries) != 1 || entries[0].ID != 7 || !entries[0].FollowedByViewer {
+			t.Errorf("expected a follower the viewer follows but got %+v", entries)
+		}
+		if followers.viewerID != 1 {
+			t.Errorf("expected the list for viewer 1 but got %d", followers.viewerID)
+		}
+	})
+
+	t.Run("should pass the cursor on and stop at the last page", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/42/following?limit=5&cursor=next-page", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if followers.query.Cursor != "next-page" || followers.query.Limit != 5 {
+			t.Errorf("expected the cursor and limit of the request but got %+v", followers.query)
+		}
+		if got := rr.Header().Get("Link"); got != "" {
+			t.Errorf("expected no Link header on the last page but got %s", got)
+		}
+
+		var entries []store.FollowListEntry
+		readData(t, rr, &entries)
+		if len(entries) != 1 || entries[0].ID != 8 || entries[0].FollowedByViewer {
+			t.Errorf("expected a user the viewer does not follow but got %+v", entries)
+		}
+	})
+
+	t.Run("should reject limits out of range", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/42/followers?limit=1000", "")
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should show follower counts on the profile", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/42", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var profile UserProfile
+		readData(t, rr, &profile)
+		if profile.User == nil || profile.ID != 42 || profile.Followers != 3 || profile.Following != 5 {
+			t.Errorf("expected user 42 with 3 followers and 5 followed but got %+v", profile)
+		}
+	})
+}
+
+func TestFollowRequests(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	followers := &fakeFollowerStore{}
+	app.store.Followers = followers
+	app.store.Users = &privateUserStore{private: 7}
+
+	t.Run("should ask private accounts and follow public ones", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, send(http.MethodPut, "/v1/users/7/follow", "").Code)
+		checkResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/users/8/follow", "").Code)
+
+		if len(followers.requested) != 1 || followers.requested[0] != 7 {
+			t.Errorf("expected a request to follow user 7 but got %v", followers.requested)
+		}
+		if len(followers.followed) != 1 || followers.followed[0] != 8 {
+			t.Errorf("expected user 8 to be followed but got %v", followers.followed)
+		}
+	})
+
+	t.Run("should list pending requests", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/me/follow-requests", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var entries []store.FollowListEntry
+		readData(t, rr, &entries)
+		if len(entries) != 1 || entries[0].ID != 9 {
+			t.Errorf("expected the request of user 9 but got %+v", entries)
+		}
+	})
+
+	t.Run("should not answer requests that were never made", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodPut, "/v1/users/me/follow-requests/7/approve", "").Code)
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodDelete, "/v1/users/me/follow-requests/7", "").Code)
+	})
+}
+
+// fakeBlockStore lists one blocked and one muted user and remembers whom it
+// was asked to block or mute.
+type fakeBlockStore struct {
+	store.MockBlockStore
+	blocked  bool
+	blocks   []int64
+	mutes    []int64
+	unblocks []int64
+}
+
+func (s *fakeBlockStore) Block(ctx context.Context, userID, otherID int64) error {
+	s.blocks = append(s.blocks, otherID)
+	return nil
+}
+
+func (s *fakeBlockStore) Unblock(ctx context.Context, userID, otherID int64) error {
+	s.unblocks = append(s.unblocks, otherID)
+	return nil
+}
+
+func (s *fakeBlockStore) Mute(ctx context.Context, userID, otherID int64) error {
+	s.mutes = append(s.mutes, otherID)
+	return nil
+}
+
+func (s *fakeBlockStore) IsBlocked(context.Context, int64, int64) (bool, error) {
+	return s.blocked, nil
+}
+
+func (s *fakeBlockStore) GetBlocked(context.Context, int64, store.FollowListQuery) ([]*store.BlockedUser, string, error) {
+	return []*store.BlockedUser{{ID: 7, Username: "troll"}}, "", nil
+}
+
+func (s *fakeBlockStore) GetMuted(context.Context, int64, store.FollowListQuery) ([]*store.BlockedUser, string, error) {
+	return []*store.BlockedUser{{ID: 8, Username: "chatterbox"}}, "", nil
+}
+
+func TestBlockUser(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	blocks := &fakeBlockStore{}
+	app.store.Blocks = blocks
+
+	t.Run("should block and mute", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/users/7/block", "").Code)
+		checkResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/users/8/mute", "").Code)
+
+		if len(blocks.blocks) != 1 || blocks.blocks[0] != 7 {
+			t.Errorf("expected user 7 to be blocked but got %v", blocks.blocks)
+		}
+		if len(blocks.mutes) != 1 || blocks.mutes[0] != 8 {
+			t.Errorf("expected user 8 to be muted but got %v", blocks.mutes)
+		}
+	})
+
+	t.Run("should not block yourself", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, send(http.MethodPut, "/v1/users/1/block", "").Code)
+	})
+
+	t.Run("should list blocked and muted users", func(t *testing.T) {
+		for path, want := range map[string]int64{"/v1/users/me/blocks": 7, "/v1/users/me/mutes": 8} {
+			rr := send(http.MethodGet, path, "")
+			checkResponseCode(t, http.StatusOK, rr.Code)
+
+			var users []store.BlockedUser
+			readData(t, rr, &users)
+			if len(users) != 1 || users[0].ID != want {
+				t.Errorf("expected user %d in %s but got %+v", want, path, users)
+			}
+		}
+	})
+
+	t.Run("should unblock deactivated users", func(t *testing.T) {
+		app.store.Users = &deactivatedOthersStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodPut, "/v1/users/7/block", "").Code)
+		checkResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/users/7/unblock", "").Code)
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodPut, "/v1/users/9/unblock", "").Code)
+
+		if len(blocks.unblocks) != 1 || blocks.unblocks[0] != 7 {
+			t.Errorf("expected user 7 to be unblocked but got %v", blocks.unblocks)
+		}
+	})
+
+	t.Run("should not follow across a block", func(t *testing.T) {
+		blocks.blocked = true
+
+		checkResponseCode(t, http.StatusForbidden, send(http.MethodPut, "/v1/users/7/follow", "").Code)
+	})
+}
+
+// deactivatedOthersStore holds the signed-in user 1 and deactivated accounts
+// of everyone else but user 9, who has none.
+type deactivatedOthersStore struct {
+	store.MockUserStore
+}
+
+func (s *deactivatedOthersStore) GetUserByID(ctx context.Context, userID int64) (*store.User, error) {
+	if userID != 1 {
+		return nil, store.ErrNotFound
+	}
+	return &store.User{ID: userID}, nil
+}
+
+func (s *deactivatedOthersStore) Exists(ctx context.Context, userID int64) (bool, error) {
+	return userID != 9, nil
+}
+
+func TestSearchUsers(t *testing.T) {
+	_, send := newSignedInTestApplication(t, config{})
+
+	t.Run("should search and autocomplete", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/users/search?q=gopher", "").Code)
+		checkResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/users/autocomplete?q=@go", "").Code)
+	})
+
+	t.Run("should require a query", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/users/search?q=%20", "").Code)
+		checkResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/users/autocomplete?q=@", "").Code)
+	})
+}
+
+func TestSuggestions(t *testing.T) {
+	_, send := newSignedInTestApplication(t, config{})
+
+	checkResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/users/me/suggestions", "").Code)
+	checkResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/users/me/suggestions?limit=500", "").Code)
+}
+
+type suspendingStore struct {
+	store.MockUserStore
+	suspended int64
+}
+
+func (s *suspendingStore) GetUserByID(ctx context.Context, userID int64) (*store.User, error) {
+	user := &store.User{ID: userID}
+	if userID == s.suspended {
+		user.Suspension = &store.Suspension{Reason: "spam", Until: time.Now().Add(time.Hour)}
+	}
+	return user, nil
+}
+
+func TestSuspendedUser(t *testing.T) {
+	app, send := newSignedInTestApplication(t, config{})
+
+	t.Run("should hide suspended users from others", func(t *testing.T) {
+		app.store.Users = &suspendingStore{suspended: 7}
+
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodGet, "/v1/users/7", "").Code)
+		checkResponseCode(t, http.StatusNotFound, send(http.MethodGet, "/v1/users/7/followers", "").Code)
+	})
+
+	t.Run("should reject requests of suspended users", func(t *testing.T) {
+		app.store.Users = &suspendingStore{suspended: 1}
+
+		checkResponseCode(t, http.StatusForbidden, send(http.MethodGet, "/v1/users/feed", "").Code)
+	})
+}
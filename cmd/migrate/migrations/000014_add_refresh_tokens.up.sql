@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id bigserial PRIMARY KEY,
+    token text NOT NULL UNIQUE,
+    session_id uuid NOT NULL,
+    user_id bigint NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+    rotated_at timestamp(0) with time zone,
+    revoked_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id
+ON refresh_tokens (session_id);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id
+ON refresh_tokens (user_id);
@@ -0,0 +1,8 @@
+ALTER TABLE
+    refresh_tokens
+    DROP
+        CONSTRAINT IF EXISTS fk_refresh_tokens_session;
+
+DROP INDEX IF EXISTS idx_sessions_user_id;
+
+DROP TABLE IF EXISTS sessions;
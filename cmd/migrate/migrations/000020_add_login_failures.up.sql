@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope varchar(16) NOT NULL,
+    subject text NOT NULL,
+    failures int NOT NULL DEFAULT 0,
+    last_failure_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    locked_until timestamp(0) with time zone,
+
+    PRIMARY KEY (scope, subject)
+);
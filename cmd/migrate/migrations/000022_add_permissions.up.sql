@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    name varchar(100) NOT NULL UNIQUE,
+    description text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id bigint NOT NULL,
+    permission_id bigint NOT NULL,
+
+    PRIMARY KEY (role_id, permission_id),
+    FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES permissions (id) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (name, description)
+VALUES
+    ('post.update.any', 'Update posts of other users'),
+    ('post.delete.any', 'Delete posts of other users'),
+    ('user.ban', 'Suspend and ban users')
+ON CONFLICT (name) DO NOTHING;
+
+-- keeps what the role levels allowed before: moderators update, admins do everything
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE (r.name = 'moderator' AND p.name = 'post.update.any')
+    OR r.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,8 @@
+DELETE FROM permissions WHERE name IN ('user.manage', 'role.manage');
+
+DROP INDEX IF EXISTS idx_admin_changes_target;
+
+DROP TABLE IF EXISTS admin_changes;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS deactivated_at;
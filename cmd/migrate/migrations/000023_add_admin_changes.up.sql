@@ -0,0 +1,31 @@
+-- set when an admin deactivates an account, which tells it apart from one
+-- that was never activated
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS deactivated_at timestamp(0) with time zone;
+
+CREATE TABLE IF NOT EXISTS admin_changes (
+    id bigserial PRIMARY KEY,
+    actor_id bigint,
+    target_type varchar(20) NOT NULL,
+    target_id bigint NOT NULL,
+    action varchar(50) NOT NULL,
+    details jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_changes_target
+ON admin_changes (target_type, target_id);
+
+INSERT INTO permissions (name, description)
+VALUES
+    ('user.manage', 'List users, change their role and deactivate them'),
+    ('role.manage', 'Create and edit roles and their permissions')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name IN ('user.manage', 'role.manage')
+ON CONFLICT DO NOTHING;
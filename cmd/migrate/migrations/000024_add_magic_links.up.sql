@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS magic_links (
+    token bytea PRIMARY KEY,
+    user_id bigint NOT NULL,
+    browser bytea NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_magic_links_user_id
+ON magic_links (user_id);
@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE name = 'audit.read';
+
+DROP TABLE IF EXISTS audit_events;
+
+DROP FUNCTION IF EXISTS audit_events_append_only();
@@ -0,0 +1,46 @@
+-- actor_id and target_id have no foreign keys so the trail outlives the
+-- users, posts and roles it mentions
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial PRIMARY KEY,
+    event varchar(50) NOT NULL,
+    actor_id bigint,
+    target_type varchar(20) NOT NULL DEFAULT '',
+    target_id bigint,
+    ip varchar(45) NOT NULL DEFAULT '',
+    request_id varchar(100) NOT NULL DEFAULT '',
+    metadata jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at
+ON audit_events (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_event
+ON audit_events (event, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id
+ON audit_events (actor_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_target
+ON audit_events (target_type, target_id, created_at);
+
+-- events are append-only, only the retention sweep removes them
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+BEFORE UPDATE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+INSERT INTO permissions (name, description)
+VALUES ('audit.read', 'Query the security and admin audit log')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'audit.read'
+ON CONFLICT DO NOTHING;
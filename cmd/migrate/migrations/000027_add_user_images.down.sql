@@ -0,0 +1,5 @@
+ALTER TABLE users
+DROP COLUMN IF EXISTS avatar,
+DROP COLUMN IF EXISTS avatar_keys,
+DROP COLUMN IF EXISTS banner,
+DROP COLUMN IF EXISTS banner_keys;
@@ -0,0 +1,7 @@
+-- avatar and banner map each size to its public URL, the *_keys columns list
+-- the stored blobs so they can be removed when the image is replaced
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS avatar jsonb NOT NULL DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS avatar_keys text[] NOT NULL DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS banner jsonb NOT NULL DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS banner_keys text[] NOT NULL DEFAULT '{}';
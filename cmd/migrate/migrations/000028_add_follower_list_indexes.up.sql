@@ -0,0 +1,4 @@
+-- the primary key finds a user's followers but cannot return them in order;
+-- these back the keyset pagination of both lists
+CREATE INDEX IF NOT EXISTS idx_followers_user_created ON followers (user_id, created_at DESC, follower_id DESC);
+CREATE INDEX IF NOT EXISTS idx_followers_follower_created ON followers (follower_id, created_at DESC, user_id DESC);
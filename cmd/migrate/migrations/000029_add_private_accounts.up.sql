@@ -0,0 +1,16 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS is_private boolean NOT NULL DEFAULT false;
+
+-- pending follows of private accounts, waiting for the owner to approve them
+CREATE TABLE IF NOT EXISTS follow_requests (
+    user_id bigint NOT NULL,
+    requester_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, requester_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (requester_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_user_created ON follow_requests (user_id, created_at DESC, requester_id DESC);
+CREATE INDEX IF NOT EXISTS idx_follow_requests_requester ON follow_requests (requester_id);
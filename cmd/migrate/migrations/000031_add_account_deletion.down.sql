@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS data_exports;
+
+ALTER TABLE user_invitation
+DROP CONSTRAINT IF EXISTS fk_user_invitation_user;
+
+ALTER TABLE posts
+DROP CONSTRAINT IF EXISTS fk_user,
+ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id);
+
+DROP INDEX IF EXISTS idx_comments_user_id;
+
+ALTER TABLE comments
+DROP CONSTRAINT IF EXISTS fk_comments_user,
+DROP CONSTRAINT IF EXISTS fk_comments_post;
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS deletion_scheduled_at;
@@ -0,0 +1,48 @@
+-- set while an account waits out the grace period before it is purged
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS deletion_scheduled_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled ON users (deletion_scheduled_at)
+WHERE deletion_scheduled_at IS NOT NULL;
+
+-- comments and invitations never had foreign keys and posts did not cascade,
+-- so deleting a user left rows behind or failed. Orphans are dropped first.
+DELETE FROM comments c
+WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id)
+   OR NOT EXISTS (SELECT 1 FROM users u WHERE u.id = c.user_id);
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+ADD CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments (user_id);
+
+ALTER TABLE posts
+DROP CONSTRAINT IF EXISTS fk_user,
+ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+DELETE FROM user_invitation i
+WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = i.user_id);
+
+ALTER TABLE user_invitation
+ADD CONSTRAINT fk_user_invitation_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    blob_key text,
+    url text,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    started_at timestamp(0) with time zone,
+    completed_at timestamp(0) with time zone,
+    expires_at timestamp(0) with time zone,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- one export in the works per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_open ON data_exports (user_id)
+WHERE status IN ('pending', 'processing');
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports (status, created_at);
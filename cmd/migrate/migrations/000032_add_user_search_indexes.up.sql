@@ -0,0 +1,5 @@
+-- fuzzy matching of display names, next to idx_users_username from 000008
+CREATE INDEX IF NOT EXISTS idx_users_display_name ON users USING gin (display_name gin_trgm_ops);
+
+-- prefix lookups for mention autocomplete
+CREATE INDEX IF NOT EXISTS idx_users_username_prefix ON users (lower(username) text_pattern_ops);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_user_created;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS suggestions_computed_at;
+
+DROP TABLE IF EXISTS follow_suggestions;
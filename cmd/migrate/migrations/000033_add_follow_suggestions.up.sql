@@ -0,0 +1,20 @@
+-- who-to-follow suggestions, recomputed in batches by the API servers
+CREATE TABLE IF NOT EXISTS follow_suggestions (
+    user_id bigint NOT NULL,
+    suggested_id bigint NOT NULL,
+    score double precision NOT NULL,
+    mutual_count int NOT NULL DEFAULT 0,
+    reasons text[] NOT NULL DEFAULT '{}',
+
+    PRIMARY KEY (user_id, suggested_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (suggested_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_suggestions_score ON follow_suggestions (user_id, score DESC);
+CREATE INDEX IF NOT EXISTS idx_follow_suggestions_suggested ON follow_suggestions (suggested_id);
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS suggestions_computed_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_created ON posts (user_id, created_at DESC);
@@ -0,0 +1,10 @@
+DELETE FROM role_permissions
+WHERE role_id = (SELECT id FROM roles WHERE name = 'moderator')
+    AND permission_id = (SELECT id FROM permissions WHERE name = 'user.ban');
+
+DROP INDEX IF EXISTS idx_users_suspended_until;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS suspension_reason,
+DROP COLUMN IF EXISTS suspended_until,
+DROP COLUMN IF EXISTS self_deactivated;
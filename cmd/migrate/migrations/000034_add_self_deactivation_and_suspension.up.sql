@@ -0,0 +1,17 @@
+-- a user who deactivated their own account is inactive with deactivated_at
+-- set, like one deactivated by an admin, but can get it back by signing in
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS self_deactivated boolean NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS suspended_until timestamp(0) with time zone,
+ADD COLUMN IF NOT EXISTS suspension_reason text;
+
+CREATE INDEX IF NOT EXISTS idx_users_suspended_until ON users (suspended_until)
+WHERE suspended_until IS NOT NULL;
+
+-- user.ban exists since permissions were introduced but guarded nothing, so
+-- only admins were given it
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'moderator' AND p.name = 'user.ban'
+ON CONFLICT DO NOTHING;
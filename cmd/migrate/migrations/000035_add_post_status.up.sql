@@ -0,0 +1,14 @@
+-- publish_at is when a scheduled post goes live, and when a published one
+-- did. Drafts have none.
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS status varchar(20) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published')),
+ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+
+UPDATE posts SET publish_at = created_at WHERE publish_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts (publish_at)
+WHERE status = 'scheduled';
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_unpublished ON posts (user_id, updated_at)
+WHERE status <> 'published';
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS admin_changes (
+    id bigserial PRIMARY KEY,
+    actor_id bigint,
+    target_type varchar(20) NOT NULL,
+    target_id bigint NOT NULL,
+    action varchar(50) NOT NULL,
+    details jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_changes_target
+ON admin_changes (target_type, target_id);
+
+INSERT INTO admin_changes (actor_id, target_type, target_id, action, details, created_at)
+SELECT u.id, a.target_type, a.target_id, m.action, a.metadata, a.created_at
+FROM audit_events a
+JOIN (
+    VALUES
+        ('user.role', 'user.role_changed'),
+        ('user.deactivate', 'user.deactivated'),
+        ('user.suspend', 'user.suspended'),
+        ('user.unsuspend', 'user.unsuspended'),
+        ('role.create', 'role.created'),
+        ('role.update', 'role.updated')
+) AS m (action, event) ON m.event = a.event
+LEFT JOIN users u ON u.id = a.actor_id
+WHERE a.target_id IS NOT NULL;
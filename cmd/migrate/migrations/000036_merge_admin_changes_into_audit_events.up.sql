@@ -0,0 +1,26 @@
+-- admin changes are now audit events. Most were already logged twice, so
+-- only the ones the audit log is missing are carried over.
+INSERT INTO audit_events (event, actor_id, target_type, target_id, metadata, created_at)
+SELECT m.event, c.actor_id, c.target_type, c.target_id, c.details, c.created_at
+FROM admin_changes c
+JOIN (
+    VALUES
+        ('user.role', 'user.role_changed'),
+        ('user.deactivate', 'user.deactivated'),
+        ('user.suspend', 'user.suspended'),
+        ('user.unsuspend', 'user.unsuspended'),
+        ('role.create', 'role.created'),
+        ('role.update', 'role.updated')
+) AS m (action, event) ON m.action = c.action
+WHERE NOT EXISTS (
+    SELECT 1 FROM audit_events a
+    WHERE a.event = m.event
+        AND a.target_type = c.target_type
+        AND a.target_id = c.target_id
+        AND a.actor_id IS NOT DISTINCT FROM c.actor_id
+        AND a.created_at BETWEEN c.created_at - interval '1 minute' AND c.created_at + interval '1 minute'
+);
+
+DROP INDEX IF EXISTS idx_admin_changes_target;
+
+DROP TABLE IF EXISTS admin_changes;
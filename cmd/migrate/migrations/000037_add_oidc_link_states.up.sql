@@ -0,0 +1,3 @@
+-- user_id is set when a signed-in user links a provider to their account
+ALTER TABLE oidc_login_states
+ADD COLUMN IF NOT EXISTS user_id bigint REFERENCES users (id) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE name = 'comment.hide';
+
+ALTER TABLE comments
+DROP COLUMN IF EXISTS hidden_at;
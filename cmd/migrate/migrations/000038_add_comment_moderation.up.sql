@@ -0,0 +1,13 @@
+-- hidden comments are kept for the record but no longer shown
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS hidden_at timestamp(0) with time zone;
+
+INSERT INTO permissions (name, description)
+VALUES ('comment.hide', 'Hide comments of other users')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('moderator', 'admin') AND p.name = 'comment.hide'
+ON CONFLICT DO NOTHING;
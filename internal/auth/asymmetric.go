@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// AsymmetricJWTAuthenticator signs tokens with a private key and verifies them
+// with any of its registered public keys, looked up by the "kid" header. Keeping
+// the previous keys registered after a rotation lets tokens they signed stay
+// valid until they expire.
+type AsymmetricJWTAuthenticator struct {
+	mu         sync.RWMutex
+	method     jwt.SigningMethod
+	keys       map[string]crypto.Signer
+	kids       []string
+	signingKID string
+	aud        string
+	iss        string
+}
+
+func NewRS256Authenticator(aud, iss string, keys ...*rsa.PrivateKey) (*AsymmetricJWTAuthenticator, error) {
+	signers := make([]crypto.Signer, len(keys))
+	for i, key := range keys {
+		signers[i] = key
+	}
+
+	return newAsymmetricJWTAuthenticator(jwt.SigningMethodRS256, aud, iss, signers)
+}
+
+func NewEdDSAAuthenticator(aud, iss string, keys ...ed25519.PrivateKey) (*AsymmetricJWTAuthenticator, error) {
+	signers := make([]crypto.Signer, len(keys))
+	for i, key := range keys {
+		signers[i] = key
+	}
+
+	return newAsymmetricJWTAuthenticator(jwt.SigningMethodEdDSA, aud, iss, signers)
+}
+
+func newAsymmetricJWTAuthenticator(method jwt.SigningMethod, aud, iss string, keys []crypto.Signer) (*AsymmetricJWTAuthenticator, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s authenticator needs at least one key", method.Alg())
+	}
+
+	a := &AsymmetricJWTAuthenticator{
+		method: method,
+		keys:   make(map[string]crypto.Signer),
+		aud:    aud,
+		iss:    iss,
+	}
+
+	// the first key signs, the others are kept for verification only
+	for i := len(keys) - 1; i >= 0; i-- {
+		if err := a.Rotate(keys[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// Rotate registers key and makes it the signing key. The previous keys stay
+// registered for verification until they are retired.
+func (a *AsymmetricJWTAuthenticator) Rotate(key crypto.Signer) error {
+	if err := a.checkKeyType(key); err != nil {
+		return err
+	}
+
+	kid, err := thumbprint(key.Public())
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.keys[kid]; !ok {
+		a.kids = append(a.kids, kid)
+	}
+	a.keys[kid] = key
+	a.signingKID = kid
+
+	return nil
+}
+
+// Retire removes a verification key. Tokens signed by it stop validating.
+func (a *AsymmetricJWTAuthenticator) Retire(kid string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.keys[kid]; !ok {
+		return ErrUnknownKey
+	}
+
+	if kid == a.signingKID {
+		return fmt.Errorf("cannot retire the signing key %s", kid)
+	}
+
+	delete(a.keys, kid)
+	for i, k := range a.kids {
+		if k == kid {
+			a.kids = append(a.kids[:i], a.kids[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (a *AsymmetricJWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	a.mu.RLock()
+	kid := a.signingKID
+	key := a.keys[kid]
+	a.mu.RUnlock()
+
+	token := jwt.NewWithClaims(a.method, claims)
+	token.Header["kid"] = kid
+
+	tokenString, err := token.SignedString(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (a *AsymmetricJWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		a.mu.RLock()
+		key, ok := a.keys[kid]
+		a.mu.RUnlock()
+
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+
+		return key.Public(), nil
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods([]string{a.method.Alg()}),
+	)
+}
+
+// JWKS returns the public half of every registered key.
+func (a *AsymmetricJWTAuthenticator) JWKS() JWKS {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, kid := range a.kids {
+		jwk, err := newJWK(kid, a.method.Alg(), a.keys[kid].Public())
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func (a *AsymmetricJWTAuthenticator) checkKeyType(key crypto.Signer) error {
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		if a.method == jwt.SigningMethodRS256 {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if a.method == jwt.SigningMethodEdDSA {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("key of type %T cannot sign %s tokens", key, a.method.Alg())
+}
+
+// LoadRSAPrivateKeys reads PEM encoded RSA private keys from the given files.
+func LoadRSAPrivateKeys(paths []string) ([]*rsa.PrivateKey, error) {
+	keys := make([]*rsa.PrivateKey, 0, len(paths))
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// LoadEdDSAPrivateKeys reads PEM encoded Ed25519 private keys from the given files.
+func LoadEdDSAPrivateKeys(paths []string) ([]ed25519.PrivateKey, error) {
+	keys := make([]ed25519.PrivateKey, 0, len(paths))
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an ed25519 private key", path)
+		}
+		keys = append(keys, edKey)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": 1,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+		"aud": "gophersocial",
+		"iss": "gophersocial",
+	}
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAsymmetricRoundTrip(t *testing.T) {
+	rs256, err := NewRS256Authenticator("gophersocial", "gophersocial", newRSAKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eddsa, err := NewEdDSAAuthenticator("gophersocial", "gophersocial", newEd25519Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, a := range map[string]*AsymmetricJWTAuthenticator{"RS256": rs256, "EdDSA": eddsa} {
+		t.Run("should sign and verify "+name+" tokens", func(t *testing.T) {
+			signed, err := a.GenerateToken(newClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := a.ValidateToken(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if token.Method.Alg() != name {
+				t.Errorf("expected a %s token but got %s", name, token.Method.Alg())
+			}
+			if kid := token.Header["kid"]; kid != a.JWKS().Keys[0].Kid {
+				t.Errorf("expected the kid of the signing key but got %v", kid)
+			}
+		})
+	}
+
+	t.Run("should reject tokens for another audience", func(t *testing.T) {
+		claims := newClaims()
+		claims["aud"] = "someone-else"
+
+		signed, err := rs256.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := rs256.ValidateToken(signed); err == nil {
+			t.Error("expected the token to be rejected")
+		}
+	})
+}
+
+func TestAsymmetricRotation(t *testing.T) {
+	oldKey, newKey := newEd25519Key(t), newEd25519Key(t)
+
+	a, err := NewEdDSAAuthenticator("gophersocial", "gophersocial", oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKID := a.JWKS().Keys[0].Kid
+
+	oldToken, err := a.GenerateToken(newClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Rotate(newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should sign with the new key", func(t *testing.T) {
+		signed, err := a.GenerateToken(newClaims())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := a.ValidateToken(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Header["kid"] == oldKID {
+			t.Error("expected the new key to sign")
+		}
+	})
+
+	t.Run("should keep validating tokens of the previous key", func(t *testing.T) {
+		if _, err := a.ValidateToken(oldToken); err != nil {
+			t.Errorf("expected the token to validate: %v", err)
+		}
+
+		if n := len(a.JWKS().Keys); n != 2 {
+			t.Errorf("expected both keys to be published but got %d", n)
+		}
+	})
+
+	t.Run("should not retire the signing key", func(t *testing.T) {
+		signingKID := a.JWKS().Keys[1].Kid
+
+		if err := a.Retire(signingKID); err == nil {
+			t.Error("expected retiring the signing key to fail")
+		}
+		if err := a.Retire("unknown"); err != ErrUnknownKey {
+			t.Errorf("expected %v but got %v", ErrUnknownKey, err)
+		}
+	})
+
+	t.Run("should reject tokens of a retired key", func(t *testing.T) {
+		if err := a.Retire(oldKID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := a.ValidateToken(oldToken); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("expected %v but got %v", ErrUnknownKey, err)
+		}
+		if n := len(a.JWKS().Keys); n != 1 {
+			t.Errorf("expected one published key but got %d", n)
+		}
+	})
+}
+
+func TestAsymmetricRejects(t *testing.T) {
+	key := newRSAKey(t)
+
+	a, err := NewRS256Authenticator("gophersocial", "gophersocial", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := a.JWKS().Keys[0].Kid
+
+	t.Run("should reject an unknown kid", func(t *testing.T) {
+		other, err := NewRS256Authenticator("gophersocial", "gophersocial", newRSAKey(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed, err := other.GenerateToken(newClaims())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := a.ValidateToken(signed); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("expected %v but got %v", ErrUnknownKey, err)
+		}
+	})
+
+	t.Run("should reject another algorithm", func(t *testing.T) {
+		for _, method := range []jwt.SigningMethod{jwt.SigningMethodRS512, jwt.SigningMethodPS256} {
+			token := jwt.NewWithClaims(method, newClaims())
+			token.Header["kid"] = kid
+
+			signed, err := token.SignedString(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := a.ValidateToken(signed); err == nil {
+				t.Errorf("expected a %s token to be rejected", method.Alg())
+			}
+		}
+	})
+
+	t.Run("should reject an HMAC token keyed with the public key", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims())
+		token.Header["kid"] = kid
+
+		signed, err := token.SignedString([]byte(a.JWKS().Keys[0].N))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := a.ValidateToken(signed); err == nil {
+			t.Error("expected the token to be rejected")
+		}
+	})
+
+	t.Run("should refuse keys of the wrong type", func(t *testing.T) {
+		if err := a.Rotate(newEd25519Key(t)); err == nil {
+			t.Error("expected an ed25519 key to be refused for RS256")
+		}
+
+		if _, err := NewEdDSAAuthenticator("gophersocial", "gophersocial"); err == nil {
+			t.Error("expected an authenticator without keys to be refused")
+		}
+	})
+}
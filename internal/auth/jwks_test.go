@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func decodeBase64URL(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestThumbprint(t *testing.T) {
+	t.Run("should match the RSA example of RFC 7638", func(t *testing.T) {
+		n := "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(decodeBase64URL(t, n)), E: 65537}
+
+		kid, err := thumbprint(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; kid != want {
+			t.Errorf("expected %s but got %s", want, kid)
+		}
+	})
+
+	t.Run("should match the Ed25519 example of RFC 8037", func(t *testing.T) {
+		pub := ed25519.PublicKey(decodeBase64URL(t, "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"))
+
+		kid, err := thumbprint(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; kid != want {
+			t.Errorf("expected %s but got %s", want, kid)
+		}
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	t.Run("should publish RSA keys", func(t *testing.T) {
+		key := newRSAKey(t)
+
+		a, err := NewRS256Authenticator("gophersocial", "gophersocial", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys := a.JWKS().Keys
+		if len(keys) != 1 {
+			t.Fatalf("expected one key but got %d", len(keys))
+		}
+
+		jwk := keys[0]
+		if jwk.Kty != "RSA" || jwk.Alg != "RS256" || jwk.Use != "sig" || jwk.Crv != "" || jwk.X != "" {
+			t.Errorf("unexpected key %+v", jwk)
+		}
+
+		n := new(big.Int).SetBytes(decodeBase64URL(t, jwk.N))
+		e := new(big.Int).SetBytes(decodeBase64URL(t, jwk.E))
+		if n.Cmp(key.N) != 0 || e.Int64() != int64(key.E) {
+			t.Error("expected the modulus and exponent of the key")
+		}
+	})
+
+	t.Run("should publish Ed25519 keys", func(t *testing.T) {
+		key := newEd25519Key(t)
+
+		a, err := NewEdDSAAuthenticator("gophersocial", "gophersocial", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jwk := a.JWKS().Keys[0]
+		if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" || jwk.N != "" || jwk.E != "" {
+			t.Errorf("unexpected key %+v", jwk)
+		}
+
+		if string(decodeBase64URL(t, jwk.X)) != string(key.Public().(ed25519.PublicKey)) {
+			t.Error("expected the public key")
+		}
+	})
+}
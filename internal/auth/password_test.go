@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	current := NewArgon2idHasher(8*1024, 2, 1)
+	legacy := BcryptHasher{Cost: 4}
+	hashers := NewPasswordHashers(current, legacy)
+
+	t.Run("should hash new passwords in PHC format", func(t *testing.T) {
+		encoded, err := hashers.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(encoded, "$argon2id$v=19$m=8192,t=2,p=1$") {
+			t.Fatalf("unexpected hash %q", encoded)
+		}
+
+		if err := hashers.Verify("correct horse", encoded); err != nil {
+			t.Errorf("expected the password to match: %v", err)
+		}
+
+		if err := hashers.Verify("wrong horse", encoded); err != ErrPasswordMismatch {
+			t.Errorf("expected ErrPasswordMismatch but got %v", err)
+		}
+
+		if hashers.NeedsRehash(encoded) {
+			t.Error("expected a current hash not to need a rehash")
+		}
+	})
+
+	t.Run("should verify and upgrade legacy bcrypt hashes", func(t *testing.T) {
+		encoded, err := legacy.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := hashers.Verify("correct horse", encoded); err != nil {
+			t.Errorf("expected the password to match: %v", err)
+		}
+
+		if !hashers.NeedsRehash(encoded) {
+			t.Error("expected a bcrypt hash to need a rehash")
+		}
+	})
+
+	t.Run("should upgrade hashes made with weaker parameters", func(t *testing.T) {
+		encoded, err := NewArgon2idHasher(4*1024, 1, 1).Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := hashers.Verify("correct horse", encoded); err != nil {
+			t.Errorf("expected the password to match: %v", err)
+		}
+
+		if !hashers.NeedsRehash(encoded) {
+			t.Error("expected a weaker hash to need a rehash")
+		}
+	})
+}
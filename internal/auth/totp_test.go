@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B lists 8 digit codes; ours are their last 6 digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != expected {
+			t.Errorf("expected code %s at %d but got %s", expected, unix, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := now.Unix() / totpPeriod
+
+	code, err := TOTPCode(rfc6238Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should accept the current code and return its step", func(t *testing.T) {
+		got, ok := ValidateTOTP(rfc6238Secret, code, now)
+		if !ok || got != step {
+			t.Errorf("expected step %d but got %d, %v", step, got, ok)
+		}
+	})
+
+	t.Run("should allow one period of drift", func(t *testing.T) {
+		for _, drift := range []time.Duration{-totpPeriod * time.Second, totpPeriod * time.Second} {
+			if got, ok := ValidateTOTP(rfc6238Secret, code, now.Add(drift)); !ok || got != step {
+				t.Errorf("expected step %d with a drift of %s but got %d, %v", step, drift, got, ok)
+			}
+		}
+	})
+
+	t.Run("should reject codes further off", func(t *testing.T) {
+		for _, drift := range []time.Duration{-2 * totpPeriod * time.Second, 2 * totpPeriod * time.Second} {
+			if _, ok := ValidateTOTP(rfc6238Secret, code, now.Add(drift)); ok {
+				t.Errorf("expected the code to be rejected with a drift of %s", drift)
+			}
+		}
+	})
+
+	t.Run("should reject malformed codes and secrets", func(t *testing.T) {
+		if _, ok := ValidateTOTP(rfc6238Secret, code[:5], now); ok {
+			t.Error("expected a short code to be rejected")
+		}
+		if _, ok := ValidateTOTP("not base32!", code, now); ok {
+			t.Error("expected an invalid secret to be rejected")
+		}
+	})
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI(rfc6238Secret, "GopherSocial", "gopher@example.com")
+
+	for _, part := range []string{"otpauth://totp/GopherSocial:gopher@example.com?", "secret=" + rfc6238Secret, "issuer=GopherSocial", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("expected %q to contain %q", uri, part)
+		}
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("expected a code formatted as xxxxx-xxxxx but got %q", code)
+		}
+		if seen[code] {
+			t.Errorf("expected unique codes but got %q twice", code)
+		}
+		seen[code] = true
+	}
+}
@@ -0,0 +1,24 @@
+// Package blob stores uploaded files under keys and tells where they can be
+// downloaded from.
+package blob
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid blob key")
+	ErrNotFound   = errors.New("blob not found")
+)
+
+type Store interface {
+	// Put stores data under key, replacing what was there.
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get reads key back. A missing key is ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public address of key.
+	URL(key string) string
+}
@@ -0,0 +1,140 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a local stand-in for an S3 bucket that keeps objects in memory
+// and checks the parts of each request it can without the secret key.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method == http.MethodGet {
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		w.Write(data)
+		return
+	}
+
+	auth := r.Header.Get("Authorization")
+	wantPrefix := "AWS4-HMAC-SHA256 Credential=AKID/20250102/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(auth, wantPrefix) || len(auth) != len(wantPrefix)+64 {
+		f.t.Errorf("unexpected authorization header %q", auth)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if got := r.Header.Get("X-Amz-Date"); got != "20250102T030405Z" {
+		f.t.Errorf("unexpected x-amz-date %q", got)
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	hash := sha256.Sum256(body)
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != hex.EncodeToString(hash[:]) {
+		f.t.Errorf("payload hash %q does not match the body", got)
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{t: t, objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s, err := NewS3Store(S3Config{
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		Bucket:    "media",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	ctx := context.Background()
+	key := "avatars/1/abc/128.jpg"
+
+	t.Run("should upload objects that are served from their URL", func(t *testing.T) {
+		if err := s.Put(ctx, key, "image/jpeg", []byte("jpeg bytes")); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.Get(s.URL(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+		if string(body) != "jpeg bytes" || res.Header.Get("Content-Type") != "image/jpeg" {
+			t.Errorf("got %q as %q from %s", body, res.Header.Get("Content-Type"), s.URL(key))
+		}
+	})
+
+	t.Run("should read objects back", func(t *testing.T) {
+		data, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "jpeg bytes" {
+			t.Errorf("expected %q but got %q", "jpeg bytes", data)
+		}
+
+		if _, err := s.Get(ctx, "avatars/missing.jpg"); err != ErrNotFound {
+			t.Errorf("expected %v but got %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("should delete objects, including missing ones", func(t *testing.T) {
+		for range 2 {
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, ok := fake.objects["/media/"+key]; ok {
+			t.Error("object was not deleted")
+		}
+	})
+}
+
+func TestSigningKey(t *testing.T) {
+	// example from the AWS Signature Version 4 documentation
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("expected signing key %s but got %s", want, got)
+	}
+}
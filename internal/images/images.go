@@ -0,0 +1,156 @@
+// Package images turns uploaded pictures into the fixed sizes we serve.
+// Every output is decoded and encoded again, which drops EXIF and any other
+// metadata the upload carried. The EXIF orientation of a JPEG is applied
+// before it is dropped, so photos stay upright.
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type, use JPEG, PNG or GIF")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// Variant is an output size. The source is cropped around its centre to the
+// aspect ratio of the variant and then scaled to fit exactly.
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Rendition is an encoded variant.
+type Rendition struct {
+	Name        string
+	ContentType string
+	Ext         string
+	Data        []byte
+}
+
+// Process checks that data is a JPEG, PNG or GIF by its content, whatever the
+// client claimed, and renders every variant from it. JPEGs stay JPEGs, other
+// formats become PNGs so transparency survives. GIFs lose their animation.
+func Process(data []byte, maxPixels int, variants []Variant) ([]*Rendition, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	// the header is checked first so a small file that claims huge
+	// dimensions is turned down before it is decoded
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	if contentType == "image/jpeg" {
+		rgba = orient(rgba, jpegOrientation(data))
+	}
+
+	renditions := make([]*Rendition, 0, len(variants))
+	for _, v := range variants {
+		img := resize(rgba, cropRect(rgba.Bounds(), v.Width, v.Height), v.Width, v.Height)
+
+		var buf bytes.Buffer
+		r := &Rendition{Name: v.Name}
+
+		if contentType == "image/jpeg" {
+			r.ContentType, r.Ext = "image/jpeg", ".jpg"
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		} else {
+			r.ContentType, r.Ext = "image/png", ".png"
+			err = png.Encode(&buf, img)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r.Data = buf.Bytes()
+		renditions = append(renditions, r)
+	}
+
+	return renditions, nil
+}
+
+// cropRect returns the largest centred rectangle of b with the aspect ratio
+// width:height.
+func cropRect(b image.Rectangle, width, height int) image.Rectangle {
+	w, h := b.Dx(), b.Dy()
+
+	if w*height > h*width {
+		cw := h * width / height
+		x := b.Min.X + (w-cw)/2
+		return image.Rect(x, b.Min.Y, x+cw, b.Max.Y)
+	}
+
+	ch := w * height / width
+	y := b.Min.Y + (h-ch)/2
+	return image.Rect(b.Min.X, y, b.Max.X, y+ch)
+}
+
+// resize scales the rect part of src to width by height. Each output pixel
+// is the average of the source pixels it covers, which keeps downscaled
+// pictures smooth; upscaling repeats pixels.
+func resize(src *image.RGBA, rect image.Rectangle, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := rect.Dx(), rect.Dy()
+
+	for y := 0; y < height; y++ {
+		y0 := rect.Min.Y + y*sh/height
+		y1 := rect.Min.Y + (y+1)*sh/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := rect.Min.X + x*sw/width
+			x1 := rect.Min.X + (x+1)*sw/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(src.Pix[i])
+					g += uint64(src.Pix[i+1])
+					b += uint64(src.Pix[i+2])
+					a += uint64(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,226 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// withExif inserts an APP1 Exif segment right after the JPEG start marker.
+func withExif(data []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS 52.5200 N 13.4050 E")...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// withOrientation inserts an Exif segment holding only the Orientation tag,
+// in the given byte order.
+func withOrientation(data []byte, orientation uint16, order byteOrder) []byte {
+	tiff := []byte("MM\x00*")
+	if order == binary.LittleEndian {
+		tiff = []byte("II*\x00")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, exifOrientationTag)
+	tiff = order.AppendUint16(tiff, 3) // SHORT
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// halvesJPEG is red on its left half and blue on its right.
+func halvesJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	photo := testJPEG(t, 16, 16)
+
+	if o := jpegOrientation(photo); o != 1 {
+		t.Errorf("expected 1 without EXIF but got %d", o)
+	}
+	if o := jpegOrientation(withExif(photo)); o != 1 {
+		t.Errorf("expected 1 for EXIF without the tag but got %d", o)
+	}
+
+	for _, order := range []byteOrder{binary.BigEndian, binary.LittleEndian} {
+		if o := jpegOrientation(withOrientation(photo, 6, order)); o != 6 {
+			t.Errorf("%s: expected 6 but got %d", order, o)
+		}
+	}
+
+	if o := jpegOrientation(withOrientation(photo, 42, binary.BigEndian)); o != 1 {
+		t.Errorf("expected 1 for an invalid orientation but got %d", o)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 1 2 3
+	// 4 5 6
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		src.Pix[i*4] = uint8(i + 1)
+	}
+
+	want := map[int][]uint8{
+		1: {1, 2, 3, 4, 5, 6},
+		2: {3, 2, 1, 6, 5, 4},
+		3: {6, 5, 4, 3, 2, 1},
+		4: {4, 5, 6, 1, 2, 3},
+		5: {1, 4, 2, 5, 3, 6},
+		6: {4, 1, 5, 2, 6, 3},
+		7: {6, 3, 5, 2, 4, 1},
+		8: {3, 6, 2, 5, 1, 4},
+	}
+
+	for orientation, pixels := range want {
+		dst := orient(src, orientation)
+
+		got := make([]uint8, 0, 6)
+		for i := 0; i < len(dst.Pix); i += 4 {
+			got = append(got, dst.Pix[i])
+		}
+
+		if !bytes.Equal(got, pixels) {
+			t.Errorf("orientation %d: expected %v but got %v", orientation, pixels, got)
+		}
+		if orientation >= 5 && (dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 3) {
+			t.Errorf("orientation %d: expected a 2x3 image but got %v", orientation, dst.Bounds())
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	variants := []Variant{
+		{Name: "128", Width: 128, Height: 128},
+		{Name: "banner", Width: 300, Height: 100},
+	}
+
+	t.Run("should render every variant at its size", func(t *testing.T) {
+		renditions, err := Process(testJPEG(t, 640, 480), 1<<20, variants)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, r := range renditions {
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(r.Data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := variants[i]
+			if format != "jpeg" || cfg.Width != v.Width || cfg.Height != v.Height {
+				t.Errorf("variant %s: got a %dx%d %s", v.Name, cfg.Width, cfg.Height, format)
+			}
+		}
+	})
+
+	t.Run("should strip EXIF", func(t *testing.T) {
+		upload := withExif(testJPEG(t, 64, 64))
+		if !bytes.Contains(upload, []byte("Exif")) {
+			t.Fatal("test image has no EXIF")
+		}
+
+		renditions, err := Process(upload, 1<<20, variants)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, r := range renditions {
+			if bytes.Contains(r.Data, []byte("Exif")) || bytes.Contains(r.Data, []byte("GPS")) {
+				t.Errorf("variant %s still carries EXIF", r.Name)
+			}
+		}
+	})
+
+	t.Run("should turn photos upright", func(t *testing.T) {
+		// turned a quarter right, the red half ends up on top
+		upload := withOrientation(halvesJPEG(t, 80, 40), 6, binary.BigEndian)
+
+		renditions, err := Process(upload, 1<<20, []Variant{{Name: "40", Width: 40, Height: 40}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(renditions[0].Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		top, bottom := color.RGBAModel.Convert(img.At(35, 5)).(color.RGBA), color.RGBAModel.Convert(img.At(5, 35)).(color.RGBA)
+		if top.R < 200 || top.B > 50 || bottom.B < 200 || bottom.R > 50 {
+			t.Errorf("expected red above blue but got %v above %v", top, bottom)
+		}
+	})
+
+	t.Run("should sniff the content instead of trusting the name", func(t *testing.T) {
+		_, err := Process([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), 1<<20, variants)
+		if err != ErrUnsupportedType {
+			t.Errorf("expected ErrUnsupportedType but got %v", err)
+		}
+	})
+
+	t.Run("should refuse images with too many pixels", func(t *testing.T) {
+		_, err := Process(testJPEG(t, 200, 200), 100*100, variants)
+		if err != ErrTooManyPixels {
+			t.Errorf("expected ErrTooManyPixels but got %v", err)
+		}
+	})
+}
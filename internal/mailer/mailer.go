@@ -3,9 +3,16 @@ package mailer
 import "embed"
 
 const (
-	fromName            = "Gopher"
-	maxRetires          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	fromName                   = "Gopher"
+	maxRetires                 = 3
+	UserWelcomeTemplate        = "user_invitation.tmpl"
+	PasswordResetTemplate      = "password_reset.tmpl"
+	AccountLockedTemplate      = "account_locked.tmpl"
+	EmailChangeConfirmTemplate = "email_change_confirm.tmpl"
+	EmailChangeNoticeTemplate  = "email_change_notice.tmpl"
+	MagicLinkTemplate          = "magic_link.tmpl"
+	DataExportReadyTemplate    = "data_export_ready.tmpl"
+	AccountDeletionTemplate    = "account_deletion.tmpl"
 )
 
 //go:embed "templates"
@@ -0,0 +1,149 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// stubProvider is a minimal identity provider: discovery, JWKS and a token
+// endpoint that checks the PKCE verifier against the challenge it was given.
+type stubProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &stubProvider{key: key}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 stub.server.URL,
+			"authorization_endpoint": stub.server.URL + "/authorize",
+			"token_endpoint":         stub.server.URL + "/token",
+			"jwks_uri":               stub.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "stub-key",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		if r.Form.Get("code") != "good-code" || PKCEChallenge(r.Form.Get("code_verifier")) != stub.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            stub.server.URL,
+			"aud":            "client-id",
+			"sub":            "external-42",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          stub.nonce,
+			"email":          "gopher@example.com",
+			"email_verified": true,
+		})
+		token.Header["kid"] = "stub-key"
+
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+
+	return stub
+}
+
+func TestProvider(t *testing.T) {
+	stub := newStubProvider(t)
+	ctx := context.Background()
+
+	provider := NewProvider(Config{
+		Name:        "stub",
+		Issuer:      stub.server.URL,
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost:8080/v1/authentication/oidc/stub/callback",
+	})
+
+	verifier, _ := RandomString()
+
+	t.Run("should build the authorization URL with PKCE", func(t *testing.T) {
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q := u.Query()
+		if q.Get("state") != "state" || q.Get("nonce") != "nonce" || q.Get("code_challenge_method") != "S256" {
+			t.Errorf("unexpected authorization parameters %v", q)
+		}
+		stub.challenge = q.Get("code_challenge")
+	})
+
+	t.Run("should verify the id token", func(t *testing.T) {
+		stub.nonce = "nonce"
+
+		claims, err := provider.Exchange(ctx, "good-code", verifier, "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if claims.Subject != "external-42" || claims.Email != "gopher@example.com" || !claims.EmailVerified {
+			t.Errorf("unexpected claims %+v", claims)
+		}
+	})
+
+	t.Run("should reject a mismatched nonce", func(t *testing.T) {
+		stub.nonce = "someone-elses-nonce"
+
+		if _, err := provider.Exchange(ctx, "good-code", verifier, "nonce"); err != ErrNonceMismatch {
+			t.Errorf("expected ErrNonceMismatch, got %v", err)
+		}
+	})
+
+	t.Run("should reject a wrong verifier", func(t *testing.T) {
+		if _, err := provider.Exchange(ctx, "good-code", "wrong-verifier", "nonce"); err == nil {
+			t.Error("expected the token exchange to fail")
+		}
+	})
+}
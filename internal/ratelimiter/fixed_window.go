@@ -22,17 +22,16 @@ func NewFixedWindowLimiter(limit int, window time.Duration) *FixedWindowRateLimi
 }
 
 func (rl *FixedWindowRateLimiter) Allow(ip string) (bool, time.Duration) {
-	rl.RLock()
-	count, exist := rl.clients[ip]
+	rl.Lock()
+	defer rl.Unlock()
 
-	rl.RUnlock()
+	count, exist := rl.clients[ip]
 
 	if !exist || count < rl.limit {
 		if !exist {
 			go rl.resetCount(ip)
 		}
 		rl.clients[ip]++
-		rl.Unlock()
 		return true, 0
 	}
 
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Audit event types.
+const (
+	AuditLoginSucceeded  = "login.succeeded"
+	AuditLoginFailed     = "login.failed"
+	AuditTokenRefreshed  = "token.refreshed"
+	AuditTokenReused     = "token.reused"
+	AuditAPIKeyCreated   = "api_key.created"
+	AuditUserActivated   = "user.activated"
+	AuditUserDeactivated = "user.deactivated"
+	AuditUserRoleChanged = "user.role_changed"
+	AuditRoleCreated     = "role.created"
+	AuditRoleUpdated     = "role.updated"
+	AuditPostModerated   = "post.deleted_by_moderator"
+	AuditCommentHidden   = "comment.hidden_by_moderator"
+
+	AuditUserDeletionScheduled = "user.deletion_scheduled"
+	AuditUserDeletionCancelled = "user.deletion_cancelled"
+	AuditUserDeleted           = "user.deleted"
+	AuditDataExportRequested   = "user.export_requested"
+	AuditDataExportDownloaded  = "user.export_downloaded"
+
+	AuditUserSelfDeactivated = "user.self_deactivated"
+	AuditUserReactivated     = "user.reactivated"
+	AuditUserSuspended       = "user.suspended"
+	AuditUserUnsuspended     = "user.unsuspended"
+	AuditLoginSuspended      = "login.suspended"
+)
+
+// AdminChangeEvents are the events of changes made through the admin API to
+// users and roles.
+var AdminChangeEvents = []string{
+	AuditUserRoleChanged,
+	AuditUserDeactivated,
+	AuditUserSuspended,
+	AuditUserUnsuspended,
+	AuditRoleCreated,
+	AuditRoleUpdated,
+}
+
+// AuditEvent is an entry of the append-only security and admin audit log.
+// ActorID and TargetID are 0 when the event has no actor or target.
+type AuditEvent struct {
+	ID         int64          `json:"id"`
+	Event      string         `json:"event"`
+	ActorID    int64          `json:"actor_id"`
+	TargetType string         `json:"target_type"`
+	TargetID   int64          `json:"target_id"`
+	IP         string         `json:"ip"`
+	RequestID  string         `json:"request_id"`
+	Metadata   map[string]any `json:"metadata"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type AuditEventStore struct {
+	db *sql.DB
+}
+
+// Create appends events in one statement. CreatedAt is kept when set, so an
+// event is stamped with when it happened rather than when it was written.
+func (s *AuditEventStore) Create(ctx context.Context, events []*AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (event, actor_id, target_type, target_id, ip, request_id, metadata, created_at)
+		SELECT e.event, NULLIF(e.actor_id, 0), e.target_type, NULLIF(e.target_id, 0), e.ip, e.request_id, e.metadata, e.created_at
+		FROM jsonb_to_recordset($1) AS e(
+			event text, actor_id bigint, target_type text, target_id bigint,
+			ip text, request_id text, metadata jsonb, created_at timestamptz
+		)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	for _, e := range events {
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = time.Now()
+		}
+		if e.Metadata == nil {
+			e.Metadata = map[string]any{}
+		}
+	}
+
+	batch, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, query, string(batch))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// createAuditEvent records event in the transaction that makes the change it
+// describes, so the record exists exactly when the change does.
+func createAuditEvent(ctx context.Context, tx *sql.Tx, event *AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (event, actor_id, target_type, target_id, ip, request_id, metadata, created_at)
+		VALUES ($1, NULLIF($2, 0), $3, NULLIF($4, 0), $5, $6, $7, $8)
+		RETURNING id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	if event.Metadata == nil {
+		event.Metadata = map[string]any{}
+	}
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return err
+	}
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		event.Event,
+		event.ActorID,
+		event.TargetType,
+		event.TargetID,
+		event.IP,
+		event.RequestID,
+		metadata,
+		event.CreatedAt,
+	).Scan(&event.ID)
+}
+
+func (s *AuditEventStore) GetAll(ctx context.Context, q AuditEventQuery) ([]*AuditEvent, error) {
+	query := `
+		SELECT id, event, COALESCE(actor_id, 0), target_type, COALESCE(target_id, 0), ip, request_id, metadata, created_at
+		FROM audit_events
+		WHERE ($1 = '' OR event = $1)
+			AND ($2 = 0 OR actor_id = $2)
+			AND ($3 = '' OR target_type = $3)
+			AND ($4 = 0 OR target_id = $4)
+			AND ($5 = '' OR ip = $5)
+			AND ($6 = '' OR request_id = $6)
+			AND ($7::timestamptz IS NULL OR created_at >= $7)
+			AND ($8::timestamptz IS NULL OR created_at < $8)
+			AND ($11::text[] IS NULL OR event = ANY($11))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $9 OFFSET $10
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var since, until *time.Time
+	if !q.Since.IsZero() {
+		since = &q.Since
+	}
+	if !q.Until.IsZero() {
+		until = &q.Until
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		q.Event,
+		q.ActorID,
+		q.TargetType,
+		q.TargetID,
+		q.IP,
+		q.RequestID,
+		since,
+		until,
+		q.Limit,
+		q.Offset,
+		pq.Array(q.Events),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		e := &AuditEvent{}
+		var metadata []byte
+		err := rows.Scan(
+			&e.ID,
+			&e.Event,
+			&e.ActorID,
+			&e.TargetType,
+			&e.TargetID,
+			&e.IP,
+			&e.RequestID,
+			&metadata,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(metadata, &e.Metadata); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// DeleteBefore removes the events older than the retention period and
+// returns how many were removed. Admin changes are kept for good, since they
+// are the only record of who changed a user or role.
+func (s *AuditEventStore) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM audit_events WHERE created_at < $1 AND event <> ALL($2)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, before, pq.Array(AdminChangeEvents))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestDB connects to the migrated database in TEST_DB_ADDR and skips the
+// test when there is none.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	addr := os.Getenv("TEST_DB_ADDR")
+	if addr == "" {
+		t.Skip("TEST_DB_ADDR is not set")
+	}
+
+	db, err := sql.Open("postgres", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestAuditEventRetention(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	events := &AuditEventStore{db: db}
+
+	requestID := "retention-" + time.Now().Format(time.RFC3339Nano)
+	old := time.Now().AddDate(-1, 0, 0)
+
+	err := events.Create(ctx, []*AuditEvent{
+		{Event: AuditLoginSucceeded, RequestID: requestID, CreatedAt: old},
+		{Event: AuditUserRoleChanged, RequestID: requestID, CreatedAt: old},
+		{Event: AuditLoginFailed, RequestID: requestID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := events.DeleteBefore(ctx, time.Now().AddDate(0, 0, -90)); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT event FROM audit_events WHERE request_id = $1 ORDER BY event`, requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var kept []string
+	for rows.Next() {
+		var event string
+		if err := rows.Scan(&event); err != nil {
+			t.Fatal(err)
+		}
+		kept = append(kept, event)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{AuditLoginFailed, AuditUserRoleChanged}
+	if len(kept) != len(want) || kept[0] != want[0] || kept[1] != want[1] {
+		t.Errorf("expected %v to survive the prune but got %v", want, kept)
+	}
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// BlockedUser is an entry in the list of users someone blocked or muted.
+type BlockedUser struct {
+	ID          int64             `json:"id"`
+	Username    string            `json:"username"`
+	DisplayName string            `json:"display_name"`
+	Avatar      map[string]string `json:"avatar,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block stops userID and blockedID from seeing or interacting with each
+// other. Follows and follow requests between them are dropped both ways.
+func (s *BlockStore) Block(ctx context.Context, userID, blockedID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		query := `
+			INSERT INTO blocks (user_id, blocked_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.ExecContext(ctx, query, userID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+		if _, err := tx.ExecContext(ctx, query, userID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM follow_requests
+			WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)
+		`
+		_, err := tx.ExecContext(ctx, query, userID, blockedID)
+		return err
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, userID, blockedID int64) error {
+	query := `
+		DELETE FROM blocks
+		WHERE user_id = $1 AND blocked_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, blockedID)
+	return err
+}
+
+// IsBlocked tells whether either of the two users blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM blocks
+			WHERE (user_id = $1 AND blocked_id = $2) OR (user_id = $2 AND blocked_id = $1)
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var blocked bool
+	if err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+// Mute keeps the posts of mutedID out of the feed of userID. Nothing else
+// changes and mutedID is not told.
+func (s *BlockStore) Mute(ctx context.Context, userID, mutedID int64) error {
+	query := `
+		INSERT INTO mutes (user_id, muted_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, mutedID)
+	return err
+}
+
+func (s *BlockStore) Unmute(ctx context.Context, userID, mutedID int64) error {
+	query := `
+		DELETE FROM mutes
+		WHERE user_id = $1 AND muted_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, mutedID)
+	return err
+}
+
+// GetBlocked lists the users userID blocked, most recent first. The returned
+// cursor fetches the next page and is empty on the last one.
+func (s *BlockStore) GetBlocked(ctx context.Context, userID int64, q FollowListQuery) ([]*BlockedUser, string, error) {
+	return s.list(ctx, "blocks", "blocked_id", userID, q)
+}
+
+// GetMuted lists the users userID muted, most recent first.
+func (s *BlockStore) GetMuted(ctx context.Context, userID int64, q FollowListQuery) ([]*BlockedUser, string, error) {
+	return s.list(ctx, "mutes", "muted_id", userID, q)
+}
+
+// list pages through table; callers pass constant names only.
+func (s *BlockStore) list(ctx context.Context, table, column string, userID int64, q FollowListQuery) ([]*BlockedUser, string, error) {
+	var after *time.Time
+	var afterID int64
+	if q.Cursor != "" {
+		t, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after, afterID = &t, id
+	}
+
+	query := fmt.Sprintf(`
+		SELECT u.id, u.username, u.display_name, u.avatar, t.created_at
+		FROM %[1]s t
+		JOIN users u ON u.id = t.%[2]s
+		WHERE t.user_id = $1
+			AND ($2::timestamptz IS NULL OR (t.created_at, t.%[2]s) < ($2, $3))
+		ORDER BY t.created_at DESC, t.%[2]s DESC
+		LIMIT $4
+	`, table, column)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, after, afterID, q.Limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	users := []*BlockedUser{}
+	for rows.Next() {
+		u := &BlockedUser{}
+		var avatar []byte
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &avatar, &u.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		if err := json.Unmarshal(avatar, &u.Avatar); err != nil {
+			return nil, "", err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(users) > q.Limit {
+		users = users[:q.Limit]
+		last := users[len(users)-1]
+		next = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return users, next, nil
+}
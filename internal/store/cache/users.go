@@ -26,13 +26,14 @@ func (s *UserStore) Get(ctx context.Context, userID int64) (*store.User, error)
 		return nil, err
 	}
 
+	if data == "" {
+		return nil, nil
+	}
+
 	var user store.User
 
-	if data == "" {
-		err := json.Unmarshal([]byte(data), &user)
-		if err != nil {
-			return nil, err
-		}
+	if err := json.Unmarshal([]byte(data), &user); err != nil {
+		return nil, err
 	}
 
 	return &user, nil
@@ -48,3 +49,9 @@ func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 	}
 	return s.rdb.SetEX(ctx, cacheKey, jsonData, UserExpTime).Err()
 }
+
+func (s *UserStore) Delete(ctx context.Context, userID int64) {
+	cacheKey := fmt.Sprintf("user-%v", userID)
+
+	s.rdb.Del(ctx, cacheKey)
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecetinerdem/gopherSocial/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+// fakeRedis answers the GET, SETEX and DEL commands the user cache sends, so
+// the cache can be tested without a Redis server.
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func newTestClient(t *testing.T) *redis.Client {
+	t.Helper()
+
+	srv := &fakeRedis{data: map[string]string{}}
+	rdb := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go srv.serve(server)
+			return client, nil
+		},
+	})
+	t.Cleanup(func() { rdb.Close() })
+
+	return rdb
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if v, ok := f.data[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SETEX":
+			f.data[args[1]] = args[3]
+			reply = "+OK\r\n"
+		case "DEL":
+			delete(f.data, args[1])
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func TestUserStore(t *testing.T) {
+	ctx := context.Background()
+	users := &UserStore{rdb: newTestClient(t)}
+
+	t.Run("should return nil for a user that is not cached", func(t *testing.T) {
+		user, err := users.Get(ctx, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != nil {
+			t.Errorf("expected no user but got %+v", user)
+		}
+	})
+
+	t.Run("should read back a cached user", func(t *testing.T) {
+		want := &store.User{
+			ID:         42,
+			Username:   "gopher",
+			Email:      "gopher@example.com",
+			Role:       store.Role{Name: "user", Level: 1},
+			Suspension: &store.Suspension{Reason: "spam", Until: time.Now().Add(time.Hour)},
+		}
+
+		if err := users.Set(ctx, want); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := users.Get(ctx, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || got.ID != want.ID || got.Username != want.Username || got.Email != want.Email || got.Role.Name != want.Role.Name {
+			t.Errorf("expected %+v but got %+v", want, got)
+		}
+		if !got.Suspended() {
+			t.Error("expected the cached user to stay suspended")
+		}
+	})
+
+	t.Run("should forget a deleted user", func(t *testing.T) {
+		users.Delete(ctx, 42)
+
+		user, err := users.Get(ctx, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != nil {
+			t.Errorf("expected no user but got %+v", user)
+		}
+	})
+}
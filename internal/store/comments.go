@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 )
 
 type Comment struct {
@@ -42,17 +43,27 @@ func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	return nil
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Comment, error) {
+// GetByPostID returns the comments on a post as viewerID sees them, leaving
+// out hidden ones and those of users blocked by or blocking the viewer and of
+// deactivated or suspended users.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID int64, viewerID int64) ([]*Comment, error) {
 	query := `
 		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id FROM comments c
 		JOIN users on users.id = c.user_id
 		WHERE c.post_id = $1
+			AND c.hidden_at IS NULL
+			AND users.is_active = true
+			AND (users.suspended_until IS NULL OR users.suspended_until <= NOW())
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.user_id = $2 AND b.blocked_id = c.user_id) OR (b.user_id = c.user_id AND b.blocked_id = $2)
+			)
 		ORDER BY c.created_at DESC
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 
 	if err != nil {
 		return nil, err
@@ -74,3 +85,28 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Commen
 	}
 	return comments, nil
 }
+
+// Hide hides the comment with the ID and post ID of comment and fills in its
+// author and content. Hiding a hidden comment returns ErrNotFound.
+func (s *CommentStore) Hide(ctx context.Context, comment *Comment) error {
+	query := `
+		UPDATE comments
+		SET hidden_at = NOW()
+		WHERE id = $1 AND post_id = $2 AND hidden_at IS NULL
+		RETURNING user_id, content
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, comment.ID, comment.PostID).Scan(&comment.UserID, &comment.Content)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// States of a data export.
+const (
+	ExportPending    = "pending"
+	ExportProcessing = "processing"
+	ExportReady      = "ready"
+	ExportFailed     = "failed"
+)
+
+// DataExport is an archive of everything a user put into the service.
+type DataExport struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Status      string     `json:"status"`
+	URL         string     `json:"url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	// Key is where the archive is stored. It is only set by GetReady.
+	Key string `json:"-"`
+}
+
+// ExportData is what goes into an export archive.
+type ExportData struct {
+	Profile   *User            `json:"profile"`
+	Posts     []*ExportPost    `json:"posts"`
+	Comments  []*ExportComment `json:"comments"`
+	Followers []*ExportFollow  `json:"followers"`
+	Following []*ExportFollow  `json:"following"`
+}
+
+type ExportPost struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ExportComment struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportFollow struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportStore struct {
+	db *sql.DB
+}
+
+// Create queues an export for userID. While another one is still being built
+// it returns ErrDataConflict.
+func (s *ExportStore) Create(ctx context.Context, userID int64) (*DataExport, error) {
+	query := `
+		INSERT INTO data_exports (user_id)
+		VALUES ($1)
+		RETURNING id, status, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	export := &DataExport{UserID: userID}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&export.ID, &export.Status, &export.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrDataConflict
+		}
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// ClaimNext marks the oldest pending export as processing and returns it, so
+// that exactly one instance builds it. An export that has been processing for
+// longer than stale is taken to be abandoned by a crashed instance and is
+// claimed again. ErrNotFound means there is nothing to do.
+func (s *ExportStore) ClaimNext(ctx context.Context, stale time.Duration) (*DataExport, error) {
+	query := `
+		UPDATE data_exports
+		SET status = 'processing', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = 'pending'
+				OR (status = 'processing' AND started_at < NOW() - make_interval(secs => $1))
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, user_id, status, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	export := &DataExport{}
+	err := s.db.QueryRowContext(ctx, query, stale.Seconds()).Scan(&export.ID, &export.UserID, &export.Status, &export.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return export, nil
+}
+
+// Complete records that the archive of export is stored under key and can be
+// downloaded from url, an API path, until expiresAt. It returns ErrNotFound
+// when the export is no longer processing, as when another instance took over
+// a stale claim and finished it first.
+func (s *ExportStore) Complete(ctx context.Context, exportID int64, key, url string, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'ready', blob_key = $2, url = $3, completed_at = NOW(), expires_at = $4
+		WHERE id = $1 AND status = 'processing'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, exportID, key, url, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *ExportStore) Fail(ctx context.Context, exportID int64) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'failed', completed_at = NOW()
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, exportID)
+	return err
+}
+
+// GetByUser lists the exports of userID that have not expired, newest first.
+func (s *ExportStore) GetByUser(ctx context.Context, userID int64) ([]*DataExport, error) {
+	query := `
+		SELECT id, user_id, status, COALESCE(url, ''), created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []*DataExport{}
+	for rows.Next() {
+		e := &DataExport{}
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Status, &e.URL, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt); err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+
+	return exports, rows.Err()
+}
+
+// GetReady returns the export of userID that can be downloaded right now.
+func (s *ExportStore) GetReady(ctx context.Context, userID, exportID int64) (*DataExport, error) {
+	query := `
+		SELECT id, user_id, status, COALESCE(url, ''), created_at, completed_at, expires_at, blob_key
+		FROM data_exports
+		WHERE id = $1 AND user_id = $2 AND status = 'ready' AND expires_at > NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	e := &DataExport{}
+	err := s.db.QueryRowContext(ctx, query, exportID, userID).Scan(&e.ID, &e.UserID, &e.Status, &e.URL, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt, &e.Key)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// DeleteExpired removes exports whose download window has passed, and failed
+// ones older than a day, returning the keys of the archives to remove.
+func (s *ExportStore) DeleteExpired(ctx context.Context) ([]string, error) {
+	query := `
+		DELETE FROM data_exports
+		WHERE expires_at <= NOW()
+			OR (status = 'failed' AND completed_at < NOW() - interval '1 day')
+		RETURNING COALESCE(blob_key, '')
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, rows.Err()
+}
+
+// GetData collects the content of userID for an export.
+func (s *ExportStore) GetData(ctx context.Context, userID int64) (*ExportData, error) {
+	users := &UserStore{s.db}
+	user, err := users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &ExportData{Profile: user}
+
+	if data.Posts, err = s.exportPosts(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Comments, err = s.exportComments(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	followers := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1
+		ORDER BY f.created_at
+	`
+	if data.Followers, err = s.exportFollows(ctx, followers, userID); err != nil {
+		return nil, err
+	}
+
+	following := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1
+		ORDER BY f.created_at
+	`
+	if data.Following, err = s.exportFollows(ctx, following, userID); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *ExportStore) exportPosts(ctx context.Context, userID int64) ([]*ExportPost, error) {
+	query := `
+		SELECT id, title, content, tags, status, created_at, updated_at
+		FROM posts
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*ExportPost{}
+	for rows.Next() {
+		p := &ExportPost{}
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, pq.Array(&p.Tags), &p.Status, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+func (s *ExportStore) exportComments(ctx context.Context, userID int64) ([]*ExportComment, error) {
+	query := `
+		SELECT id, post_id, content, created_at
+		FROM comments
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*ExportComment{}
+	for rows.Next() {
+		c := &ExportComment{}
+		if err := rows.Scan(&c.ID, &c.PostID, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+func (s *ExportStore) exportFollows(ctx context.Context, query string, userID int64) ([]*ExportFollow, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	follows := []*ExportFollow{}
+	for rows.Next() {
+		f := &ExportFollow{}
+		if err := rows.Scan(&f.UserID, &f.Username, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		follows = append(follows, f)
+	}
+
+	return follows, rows.Err()
+}
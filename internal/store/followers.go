@@ -3,6 +3,12 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -34,8 +40,14 @@ func (s *FollowerStore) Follow(ctx context.Context, followerID int64, userID int
 	}
 	return nil
 }
+
+// Unfollow stops following userID, or withdraws the request to.
 func (s *FollowerStore) Unfollow(ctx context.Context, followerID int64, userID int64) error {
 	query := `
+		WITH request AS (
+			DELETE FROM follow_requests
+			WHERE user_id = $1 AND requester_id = $2
+		)
 		DELETE FROM followers
 		WHERE user_id = $1 AND follower_id = $2
 	`
@@ -50,3 +62,293 @@ func (s *FollowerStore) Unfollow(ctx context.Context, followerID int64, userID i
 	}
 	return nil
 }
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// FollowListEntry is a user in a followers or following list.
+type FollowListEntry struct {
+	ID          int64             `json:"id"`
+	Username    string            `json:"username"`
+	DisplayName string            `json:"display_name"`
+	Avatar      map[string]string `json:"avatar,omitempty"`
+	FollowedAt  time.Time         `json:"followed_at"`
+	// FollowedByViewer tells whether the user making the request follows
+	// this user.
+	FollowedByViewer bool `json:"followed_by_viewer"`
+}
+
+type FollowCounts struct {
+	Followers int64 `json:"follower_count"`
+	Following int64 `json:"following_count"`
+}
+
+// GetFollowers lists the users following userID, most recent first. The
+// returned cursor fetches the next page and is empty on the last one.
+func (s *FollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, q FollowListQuery) ([]*FollowListEntry, string, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar, f.created_at,
+			EXISTS (SELECT 1 FROM followers v WHERE v.user_id = u.id AND v.follower_id = $2)
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1 AND u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())
+			AND ($3::timestamptz IS NULL OR (f.created_at, f.follower_id) < ($3, $4))
+		ORDER BY f.created_at DESC, f.follower_id DESC
+		LIMIT $5
+	`
+
+	return s.list(ctx, query, userID, viewerID, q)
+}
+
+// GetFollowing lists the users userID follows, most recent first.
+func (s *FollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, q FollowListQuery) ([]*FollowListEntry, string, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar, f.created_at,
+			EXISTS (SELECT 1 FROM followers v WHERE v.user_id = u.id AND v.follower_id = $2)
+		FROM followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1 AND u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())
+			AND ($3::timestamptz IS NULL OR (f.created_at, f.user_id) < ($3, $4))
+		ORDER BY f.created_at DESC, f.user_id DESC
+		LIMIT $5
+	`
+
+	return s.list(ctx, query, userID, viewerID, q)
+}
+
+func (s *FollowerStore) list(ctx context.Context, query string, userID, viewerID int64, q FollowListQuery) ([]*FollowListEntry, string, error) {
+	var after *time.Time
+	var afterID int64
+	if q.Cursor != "" {
+		t, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after, afterID = &t, id
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	// one row more than asked for tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, userID, viewerID, after, afterID, q.Limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	entries := []*FollowListEntry{}
+	for rows.Next() {
+		e := &FollowListEntry{}
+		var avatar []byte
+		if err := rows.Scan(&e.ID, &e.Username, &e.DisplayName, &avatar, &e.FollowedAt, &e.FollowedByViewer); err != nil {
+			return nil, "", err
+		}
+		if err := json.Unmarshal(avatar, &e.Avatar); err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		last := entries[len(entries)-1]
+		next = encodeCursor(last.FollowedAt, last.ID)
+	}
+
+	return entries, next, nil
+}
+
+// IsFollowing tells whether followerID follows userID.
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var following bool
+	if err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following); err != nil {
+		return false, err
+	}
+
+	return following, nil
+}
+
+// RequestFollow asks the private account userID to let followerID follow it.
+// Asking again, or asking while already following, returns ErrDataConflict.
+func (s *FollowerStore) RequestFollow(ctx context.Context, followerID, userID int64) error {
+	query := `
+		INSERT INTO follow_requests (user_id, requester_id)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, followerID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDataConflict
+		}
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrDataConflict
+	}
+
+	return nil
+}
+
+// GetFollowRequests lists the pending requests to follow userID, most recent
+// first. FollowedAt of each entry is when the request was made.
+func (s *FollowerStore) GetFollowRequests(ctx context.Context, userID int64, q FollowListQuery) ([]*FollowListEntry, string, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar, f.created_at,
+			EXISTS (SELECT 1 FROM followers v WHERE v.user_id = u.id AND v.follower_id = $2)
+		FROM follow_requests f
+		JOIN users u ON u.id = f.requester_id
+		WHERE f.user_id = $1 AND u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())
+			AND ($3::timestamptz IS NULL OR (f.created_at, f.requester_id) < ($3, $4))
+		ORDER BY f.created_at DESC, f.requester_id DESC
+		LIMIT $5
+	`
+
+	return s.list(ctx, query, userID, userID, q)
+}
+
+// ApproveFollowRequest turns the request of requesterID into a follow of
+// userID. It returns ErrNotFound when there is no such request.
+func (s *FollowerStore) ApproveFollowRequest(ctx context.Context, userID, requesterID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		if err := deleteFollowRequest(ctx, tx, userID, requesterID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO followers (user_id, follower_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, userID, requesterID)
+		return err
+	})
+}
+
+// RejectFollowRequest drops the request of requesterID to follow userID.
+func (s *FollowerStore) RejectFollowRequest(ctx context.Context, userID, requesterID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return deleteFollowRequest(ctx, tx, userID, requesterID)
+	})
+}
+
+func deleteFollowRequest(ctx context.Context, tx *sql.Tx, userID, requesterID int64) error {
+	query := `
+		DELETE FROM follow_requests
+		WHERE user_id = $1 AND requester_id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := tx.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// approveAllFollowRequests lets everyone who asked follow userID, for when
+// the account is made public.
+func approveAllFollowRequests(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		WITH approved AS (
+			DELETE FROM follow_requests
+			WHERE user_id = $1
+			RETURNING user_id, requester_id
+		)
+		INSERT INTO followers (user_id, follower_id)
+		SELECT user_id, requester_id FROM approved
+		ON CONFLICT DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
+// GetCounts returns how many active users follow userID and how many it
+// follows.
+func (s *FollowerStore) GetCounts(ctx context.Context, userID int64) (*FollowCounts, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.follower_id
+				WHERE f.user_id = $1 AND u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())),
+			(SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.user_id
+				WHERE f.follower_id = $1 AND u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW()))
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	counts := &FollowCounts{}
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&counts.Followers, &counts.Following); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// A cursor is the position of the last entry of a page in a list of users:
+// when the entry was created and the user ID, which breaks ties within the
+// same second. It is opaque to clients.
+func encodeCursor(t time.Time, id int64) string {
+	raw := t.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	ts, idStr, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	return t, id, nil
+}
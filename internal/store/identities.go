@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Identity links a user to an account at an external OpenID Connect provider.
+type Identity struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Provider  string `json:"provider"`
+	Subject   string `json:"subject"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
+
+// LoginState is what we need to remember between redirecting a browser to a
+// provider and handling its callback.
+type LoginState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	// UserID is set when a signed-in user links the identity to their account.
+	UserID int64
+}
+
+type IdentityStore struct {
+	db *sql.DB
+}
+
+// GetUser returns the user the external identity is linked to, whether or
+// not the account is active.
+func (s *IdentityStore) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+	query := `
+		SELECT users.id, users.username, users.email, users.created_at, users.is_active,
+			users.suspended_until, users.suspension_reason, roles.*
+		FROM users
+		JOIN roles on (users.role_id = roles.id)
+		JOIN user_identities ui on (ui.user_id = users.id)
+		WHERE ui.provider = $1 AND ui.subject = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	user := &User{}
+	var suspendedUntil sql.NullTime
+	var suspensionReason sql.NullString
+	err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+		&suspendedUntil,
+		&suspensionReason,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	user.setSuspension(suspendedUntil, suspensionReason)
+
+	return user, nil
+}
+
+// Link links the external identity to an existing user. It returns
+// ErrDataConflict when the identity is linked already.
+func (s *IdentityStore) Link(ctx context.Context, identity *Identity) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		err := createIdentity(ctx, tx, identity)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDataConflict
+		}
+		return err
+	})
+}
+
+func (s *IdentityStore) CreateLoginState(ctx context.Context, state *LoginState, exp time.Duration) error {
+	query := `
+		INSERT INTO oidc_login_states (state, provider, nonce, code_verifier, expiry, user_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		hashToken(state.State),
+		state.Provider,
+		state.Nonce,
+		state.CodeVerifier,
+		time.Now().Add(exp),
+		state.UserID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeLoginState returns and deletes a login state so a callback can only be
+// handled once.
+func (s *IdentityStore) ConsumeLoginState(ctx context.Context, provider, state string) (*LoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state = $1 AND provider = $2 AND expiry > $3
+		RETURNING provider, nonce, code_verifier, COALESCE(user_id, 0)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	ls := &LoginState{State: state}
+	err := s.db.QueryRowContext(ctx, query, hashToken(state), provider, time.Now()).Scan(
+		&ls.Provider,
+		&ls.Nonce,
+		&ls.CodeVerifier,
+		&ls.UserID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return ls, nil
+}
+
+func createIdentity(ctx context.Context, tx *sql.Tx, identity *Identity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	).Scan(
+		&identity.ID,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
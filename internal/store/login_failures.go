@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Scopes failed logins are counted under. Accounts are keyed by email and
+// second factors by user ID.
+const (
+	LoginScopeAccount   = "account"
+	LoginScopeIP        = "ip"
+	LoginScopeTwoFactor = "two_factor"
+)
+
+// LoginFailure counts recent failed logins for an account or an IP address.
+type LoginFailure struct {
+	Scope         string
+	Subject       string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+type LoginFailureStore struct {
+	db *sql.DB
+}
+
+func (s *LoginFailureStore) Get(ctx context.Context, scope, subject string) (*LoginFailure, error) {
+	query := `
+		SELECT scope, subject, failures, last_failure_at, locked_until
+		FROM login_failures
+		WHERE scope = $1 AND subject = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	lf := &LoginFailure{}
+	err := s.db.QueryRowContext(ctx, query, scope, subject).Scan(
+		&lf.Scope,
+		&lf.Subject,
+		&lf.Failures,
+		&lf.LastFailureAt,
+		&lf.LockedUntil,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return lf, nil
+}
+
+// Record counts a failed login. The count starts over when the previous
+// failure is older than window, and once it reaches lockAfter the subject is
+// locked for lockFor. A lockAfter of zero never locks.
+func (s *LoginFailureStore) Record(ctx context.Context, scope, subject string, window time.Duration, lockAfter int, lockFor time.Duration) (*LoginFailure, error) {
+	query := `
+		INSERT INTO login_failures (scope, subject, failures, last_failure_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (scope, subject) DO UPDATE SET
+			failures = CASE
+				WHEN login_failures.last_failure_at < NOW() - make_interval(secs => $3) THEN 1
+				ELSE login_failures.failures + 1
+			END,
+			last_failure_at = NOW(),
+			locked_until = CASE
+				WHEN $4 > 0 AND login_failures.last_failure_at >= NOW() - make_interval(secs => $3)
+					AND login_failures.failures + 1 >= $4 THEN NOW() + make_interval(secs => $5)
+				ELSE login_failures.locked_until
+			END
+		RETURNING scope, subject, failures, last_failure_at, locked_until
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	lf := &LoginFailure{}
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		scope,
+		subject,
+		window.Seconds(),
+		lockAfter,
+		lockFor.Seconds(),
+	).Scan(
+		&lf.Scope,
+		&lf.Subject,
+		&lf.Failures,
+		&lf.LastFailureAt,
+		&lf.LockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return lf, nil
+}
+
+// Reset forgets the failures of a subject after a successful login.
+func (s *LoginFailureStore) Reset(ctx context.Context, scope, subject string) error {
+	query := `
+		DELETE FROM login_failures
+		WHERE scope = $1 AND subject = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, scope, subject)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MagicLinkStore keeps the outstanding email login links. Both the token in
+// the link and the secret that binds it to the requesting browser are only
+// stored hashed.
+type MagicLinkStore struct {
+	db *sql.DB
+}
+
+// Create replaces the outstanding links of the user with a new one, so only
+// the most recently requested link works.
+func (s *MagicLinkStore) Create(ctx context.Context, userID int64, token, browser string, exp time.Duration) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		if err := deleteMagicLinks(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO magic_links (token, user_id, browser, expiry)
+			VALUES ($1, $2, $3, $4)
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, hashToken(token), userID, hashToken(browser), time.Now().Add(exp))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Consume deletes the link and returns the user it signs in. A link that is
+// presented from another browser is left untouched.
+func (s *MagicLinkStore) Consume(ctx context.Context, token, browser string) (int64, error) {
+	query := `
+		DELETE FROM magic_links
+		WHERE token = $1 AND browser = $2 AND expiry > $3
+		RETURNING user_id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var userID int64
+	err := s.db.QueryRowContext(ctx, query, hashToken(token), hashToken(browser), time.Now()).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// RevokeAllForUser invalidates every outstanding link of the user.
+func (s *MagicLinkStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return deleteMagicLinks(ctx, tx, userID)
+	})
+}
+
+func deleteMagicLinks(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		DELETE FROM magic_links WHERE user_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
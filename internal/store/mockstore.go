@@ -8,7 +8,22 @@ import (
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:         &MockUserStore{},
+		Posts:         &MockPostStore{},
+		Comments:      &MockCommentStore{},
+		Roles:         &MockRoleStore{},
+		RefreshTokens: &MockRefreshTokenStore{},
+		Sessions:      &MockSessionStore{},
+		TwoFactor:     &MockTwoFactorStore{},
+		Identities:    &MockIdentityStore{},
+		APIKeys:       &MockAPIKeyStore{},
+		LoginFailures: &MockLoginFailureStore{},
+		MagicLinks:    &MockMagicLinkStore{},
+		AuditEvents:   &MockAuditEventStore{},
+		Followers:     &MockFollowerStore{},
+		Blocks:        &MockBlockStore{},
+		Exports:       &MockExportStore{},
+		Suggestions:   &MockSuggestionStore{},
 	}
 }
 
@@ -22,18 +37,469 @@ func (m *MockUserStore) GetUserByID(ctx context.Context, userID int64) (*User, e
 	return &User{ID: userID}, nil
 }
 
+func (m *MockUserStore) Exists(ctx context.Context, userID int64) (bool, error) {
+	return true, nil
+}
+
 func (m *MockUserStore) GetByEmail(context.Context, string) (*User, error) {
 	return &User{}, nil
 }
 
+func (m *MockUserStore) GetDeactivatedByEmail(context.Context, string, time.Duration) (*User, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockUserStore) GetDeactivatedByID(context.Context, int64, time.Duration) (*User, error) {
+	return nil, ErrNotFound
+}
+
 func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error {
 	return nil
 }
 
-func (m *MockUserStore) Activate(ctx context.Context, t string) error {
+func (m *MockUserStore) CreateWithIdentity(ctx context.Context, user *User, identity *Identity) error {
 	return nil
 }
 
+func (m *MockUserStore) Activate(ctx context.Context, t string) (*User, error) {
+	return &User{}, nil
+}
+
 func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
 	return nil
 }
+
+func (m *MockUserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ResetPassword(ctx context.Context, token, newPassword string) (*User, error) {
+	return &User{}, nil
+}
+
+func (m *MockUserStore) CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ConfirmEmailChange(ctx context.Context, userID int64, token string) error {
+	return nil
+}
+
+func (m *MockUserStore) Reinvite(ctx context.Context, email, token string, exp time.Duration) (*User, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockUserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockUserStore) PruneInactive(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockUserStore) UpdatePassword(ctx context.Context, user *User) error {
+	return nil
+}
+
+// MockRoleStore grants permissions to the role with ID 3 only.
+func (m *MockUserStore) UpdateProfile(ctx context.Context, user *User) error {
+	return nil
+}
+
+func (m *MockUserStore) SetImage(ctx context.Context, userID int64, kind string, urls map[string]string, keys []string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *MockUserStore) ScheduleDeletion(context.Context, int64, time.Time) error {
+	return nil
+}
+
+func (m *MockUserStore) CancelDeletion(context.Context, int64) error {
+	return ErrNotFound
+}
+
+func (m *MockUserStore) GetDueDeletions(context.Context) ([]int64, error) {
+	return nil, nil
+}
+
+func (m *MockUserStore) Purge(context.Context, int64) ([]string, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockUserStore) Search(context.Context, int64, UserSearchQuery) ([]*UserSummary, error) {
+	return []*UserSummary{}, nil
+}
+
+func (m *MockUserStore) Autocomplete(context.Context, int64, AutocompleteQuery) ([]*UserSummary, error) {
+	return []*UserSummary{}, nil
+}
+
+type MockRoleStore struct{}
+
+func (m *MockRoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
+	return &Role{Name: name}, nil
+}
+
+func (m *MockRoleStore) HasPermission(ctx context.Context, roleID int64, permission string) (bool, error) {
+	return roleID == 3, nil
+}
+
+func (m *MockRoleStore) GetAll(ctx context.Context) ([]*Role, error) {
+	return []*Role{}, nil
+}
+
+func (m *MockRoleStore) GetPermissions(ctx context.Context) ([]*Permission, error) {
+	return []*Permission{}, nil
+}
+
+func (m *MockRoleStore) Create(ctx context.Context, role *Role, event *AuditEvent) error {
+	return nil
+}
+
+func (m *MockRoleStore) Update(ctx context.Context, role *Role, event *AuditEvent) error {
+	return nil
+}
+
+func (m *MockUserStore) List(ctx context.Context, q UserListQuery) ([]*User, error) {
+	return []*User{}, nil
+}
+
+func (m *MockUserStore) SetRole(ctx context.Context, userID int64, role *Role, event *AuditEvent) error {
+	return nil
+}
+
+func (m *MockUserStore) Deactivate(ctx context.Context, userID int64, event *AuditEvent) error {
+	return nil
+}
+
+func (m *MockUserStore) DeactivateSelf(context.Context, int64) error {
+	return nil
+}
+
+func (m *MockUserStore) Reactivate(context.Context, int64, time.Duration) error {
+	return ErrNotFound
+}
+
+func (m *MockUserStore) Suspend(context.Context, int64, string, time.Time, *AuditEvent) error {
+	return nil
+}
+
+func (m *MockUserStore) Unsuspend(context.Context, int64, *AuditEvent) error {
+	return nil
+}
+
+type MockRefreshTokenStore struct{}
+
+func (m *MockRefreshTokenStore) Rotate(ctx context.Context, token, newToken string, next *RefreshToken) error {
+	return nil
+}
+
+type MockSessionStore struct{}
+
+func (m *MockSessionStore) Create(ctx context.Context, session *Session, token string, rt *RefreshToken) error {
+	return nil
+}
+
+func (m *MockSessionStore) GetByUserID(ctx context.Context, userID int64) ([]*Session, error) {
+	return []*Session{}, nil
+}
+
+func (m *MockSessionStore) Touch(ctx context.Context, sessionID string) (bool, error) {
+	return true, nil
+}
+
+func (m *MockSessionStore) StartedWithin(ctx context.Context, userID int64, sessionID string, d time.Duration) (bool, error) {
+	return false, nil
+}
+
+func (m *MockSessionStore) Revoke(ctx context.Context, userID int64, sessionID string) error {
+	return nil
+}
+
+func (m *MockSessionStore) RevokeOthers(ctx context.Context, userID int64, keepSessionID string) error {
+	return nil
+}
+
+func (m *MockSessionStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m *MockSessionStore) DeleteEnded(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+type MockTwoFactorStore struct{}
+
+func (m *MockTwoFactorStore) Get(ctx context.Context, userID int64) (*TwoFactor, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockTwoFactorStore) Enroll(ctx context.Context, userID int64, secret string) error {
+	return nil
+}
+
+func (m *MockTwoFactorStore) Confirm(ctx context.Context, userID int64, step int64, recoveryCodes []string) error {
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseStep(ctx context.Context, userID int64, step int64) error {
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	return nil
+}
+
+func (m *MockTwoFactorStore) CreateChallenge(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockTwoFactorStore) AttemptChallenge(ctx context.Context, token string) (*User, error) {
+	return &User{ID: 1}, nil
+}
+
+func (m *MockTwoFactorStore) DeleteChallenge(ctx context.Context, token string) error {
+	return nil
+}
+
+type MockIdentityStore struct{}
+
+func (m *MockIdentityStore) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockIdentityStore) Link(ctx context.Context, identity *Identity) error {
+	return nil
+}
+
+func (m *MockIdentityStore) CreateLoginState(ctx context.Context, state *LoginState, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockIdentityStore) ConsumeLoginState(ctx context.Context, provider, state string) (*LoginState, error) {
+	return nil, ErrNotFound
+}
+
+type MockAPIKeyStore struct{}
+
+func (m *MockAPIKeyStore) Create(ctx context.Context, key *APIKey, token string) error {
+	return nil
+}
+
+func (m *MockAPIKeyStore) GetByUserID(ctx context.Context, userID int64) ([]*APIKey, error) {
+	return []*APIKey{}, nil
+}
+
+func (m *MockAPIKeyStore) Authenticate(ctx context.Context, token string) (*APIKey, error) {
+	return &APIKey{ID: 1, UserID: 1, Scopes: []string{"posts:read"}}, nil
+}
+
+func (m *MockAPIKeyStore) Revoke(ctx context.Context, userID, keyID int64) error {
+	return nil
+}
+
+type MockLoginFailureStore struct{}
+
+func (m *MockLoginFailureStore) Get(ctx context.Context, scope, subject string) (*LoginFailure, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockLoginFailureStore) Record(ctx context.Context, scope, subject string, window time.Duration, lockAfter int, lockFor time.Duration) (*LoginFailure, error) {
+	return &LoginFailure{Scope: scope, Subject: subject, Failures: 1, LastFailureAt: time.Now()}, nil
+}
+
+func (m *MockLoginFailureStore) Reset(ctx context.Context, scope, subject string) error {
+	return nil
+}
+
+type MockMagicLinkStore struct{}
+
+func (m *MockMagicLinkStore) Create(ctx context.Context, userID int64, token, browser string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockMagicLinkStore) Consume(ctx context.Context, token, browser string) (int64, error) {
+	return 0, ErrNotFound
+}
+
+func (m *MockMagicLinkStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	return nil
+}
+
+type MockAuditEventStore struct{}
+
+func (m *MockAuditEventStore) Create(ctx context.Context, events []*AuditEvent) error {
+	return nil
+}
+
+func (m *MockAuditEventStore) GetAll(ctx context.Context, q AuditEventQuery) ([]*AuditEvent, error) {
+	return []*AuditEvent{}, nil
+}
+
+func (m *MockAuditEventStore) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+type MockFollowerStore struct{}
+
+func (m *MockFollowerStore) Follow(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) Unfollow(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) GetFollowers(context.Context, int64, int64, FollowListQuery) ([]*FollowListEntry, string, error) {
+	return []*FollowListEntry{}, "", nil
+}
+
+func (m *MockFollowerStore) GetFollowing(context.Context, int64, int64, FollowListQuery) ([]*FollowListEntry, string, error) {
+	return []*FollowListEntry{}, "", nil
+}
+
+func (m *MockFollowerStore) GetCounts(context.Context, int64) (*FollowCounts, error) {
+	return &FollowCounts{}, nil
+}
+
+func (m *MockFollowerStore) IsFollowing(context.Context, int64, int64) (bool, error) {
+	return false, nil
+}
+
+func (m *MockFollowerStore) RequestFollow(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) GetFollowRequests(context.Context, int64, FollowListQuery) ([]*FollowListEntry, string, error) {
+	return []*FollowListEntry{}, "", nil
+}
+
+func (m *MockFollowerStore) ApproveFollowRequest(context.Context, int64, int64) error {
+	return ErrNotFound
+}
+
+func (m *MockFollowerStore) RejectFollowRequest(context.Context, int64, int64) error {
+	return ErrNotFound
+}
+
+type MockBlockStore struct{}
+
+func (m *MockBlockStore) Block(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockBlockStore) Unblock(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockBlockStore) IsBlocked(context.Context, int64, int64) (bool, error) {
+	return false, nil
+}
+
+func (m *MockBlockStore) Mute(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockBlockStore) Unmute(context.Context, int64, int64) error {
+	return nil
+}
+
+func (m *MockBlockStore) GetBlocked(context.Context, int64, FollowListQuery) ([]*BlockedUser, string, error) {
+	return []*BlockedUser{}, "", nil
+}
+
+func (m *MockBlockStore) GetMuted(context.Context, int64, FollowListQuery) ([]*BlockedUser, string, error) {
+	return []*BlockedUser{}, "", nil
+}
+
+type MockExportStore struct{}
+
+func (m *MockExportStore) Create(ctx context.Context, userID int64) (*DataExport, error) {
+	return &DataExport{UserID: userID, Status: ExportPending}, nil
+}
+
+func (m *MockExportStore) ClaimNext(context.Context, time.Duration) (*DataExport, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockExportStore) Complete(context.Context, int64, string, string, time.Time) error {
+	return nil
+}
+
+func (m *MockExportStore) Fail(context.Context, int64) error {
+	return nil
+}
+
+func (m *MockExportStore) GetByUser(context.Context, int64) ([]*DataExport, error) {
+	return []*DataExport{}, nil
+}
+
+func (m *MockExportStore) GetReady(context.Context, int64, int64) (*DataExport, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockExportStore) DeleteExpired(context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockExportStore) GetData(ctx context.Context, userID int64) (*ExportData, error) {
+	return &ExportData{Profile: &User{ID: userID}}, nil
+}
+
+type MockSuggestionStore struct{}
+
+func (m *MockSuggestionStore) ClaimStale(context.Context, time.Duration, int) ([]int64, error) {
+	return nil, nil
+}
+
+func (m *MockSuggestionStore) Compute(context.Context, int64) error {
+	return nil
+}
+
+func (m *MockSuggestionStore) Get(context.Context, int64, int) ([]*Suggestion, error) {
+	return []*Suggestion{}, nil
+}
+
+type MockCommentStore struct{}
+
+func (m *MockCommentStore) GetByPostID(context.Context, int64, int64) ([]*Comment, error) {
+	return []*Comment{}, nil
+}
+
+func (m *MockCommentStore) Create(context.Context, *Comment) error {
+	return nil
+}
+
+func (m *MockCommentStore) Hide(context.Context, *Comment) error {
+	return nil
+}
+
+type MockPostStore struct{}
+
+func (m *MockPostStore) GetByID(ctx context.Context, postID int64) (*Post, error) {
+	return &Post{ID: postID, Status: PostPublished}, nil
+}
+
+func (m *MockPostStore) Create(context.Context, *Post) error {
+	return nil
+}
+
+func (m *MockPostStore) Update(context.Context, *Post) error {
+	return nil
+}
+
+func (m *MockPostStore) Delete(context.Context, int64) error {
+	return nil
+}
+
+func (m *MockPostStore) GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]*PostWithMetaData, error) {
+	return []*PostWithMetaData{}, nil
+}
+
+func (m *MockPostStore) GetDrafts(context.Context, int64, DraftListQuery) ([]*Post, error) {
+	return []*Post{}, nil
+}
+
+func (m *MockPostStore) PublishDue(context.Context, int) ([]int64, error) {
+	return nil, nil
+}
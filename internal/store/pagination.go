@@ -75,3 +75,267 @@ func parseTime(since string) string {
 
 	return t.Format(time.DateTime)
 }
+
+type AdminChangeQuery struct {
+	Limit      int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset     int    `json:"offset" validate:"gte=0"`
+	TargetType string `json:"target_type" validate:"omitempty,oneof=user role"`
+	TargetID   int64  `json:"target_id" validate:"gte=0"`
+}
+
+func (q AdminChangeQuery) Parse(r *http.Request) (AdminChangeQuery, error) {
+	queryString := r.URL.Query()
+
+	if limit := queryString.Get("limit"); limit != "" {
+		lmt, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = lmt
+	}
+
+	if offset := queryString.Get("offset"); offset != "" {
+		ofst, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = ofst
+	}
+
+	q.TargetType = queryString.Get("target_type")
+
+	if targetID := queryString.Get("target_id"); targetID != "" {
+		id, err := strconv.ParseInt(targetID, 10, 64)
+		if err != nil {
+			return q, err
+		}
+		q.TargetID = id
+	}
+
+	return q, nil
+}
+
+type UserListQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Search string `json:"search" validate:"max=100"`
+	Role   string `json:"role" validate:"max=255"`
+	Active string `json:"active" validate:"omitempty,oneof=true false"`
+}
+
+func (q UserListQuery) Parse(r *http.Request) (UserListQuery, error) {
+	queryString := r.URL.Query()
+
+	if limit := queryString.Get("limit"); limit != "" {
+		lmt, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = lmt
+	}
+
+	if offset := queryString.Get("offset"); offset != "" {
+		ofst, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = ofst
+	}
+
+	q.Search = queryString.Get("search")
+	q.Role = queryString.Get("role")
+	q.Active = queryString.Get("active")
+
+	return q, nil
+}
+
+type AuditEventQuery struct {
+	Limit      int       `json:"limit" validate:"gte=1,lte=100"`
+	Offset     int       `json:"offset" validate:"gte=0"`
+	Event      string    `json:"event" validate:"max=50"`
+	ActorID    int64     `json:"actor_id" validate:"gte=0"`
+	TargetType string    `json:"target_type" validate:"omitempty,oneof=user post role session"`
+	TargetID   int64     `json:"target_id" validate:"gte=0"`
+	IP         string    `json:"ip" validate:"omitempty,ip"`
+	RequestID  string    `json:"request_id" validate:"max=100"`
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+	// Events narrows the query to any of these events. It is set by views
+	// of the log, not by the client.
+	Events []string `json:"-"`
+}
+
+func (q AuditEventQuery) Parse(r *http.Request) (AuditEventQuery, error) {
+	queryString := r.URL.Query()
+
+	if limit := queryString.Get("limit"); limit != "" {
+		lmt, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = lmt
+	}
+
+	if offset := queryString.Get("offset"); offset != "" {
+		ofst, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = ofst
+	}
+
+	q.Event = queryString.Get("event")
+	q.TargetType = queryString.Get("target_type")
+	q.IP = queryString.Get("ip")
+	q.RequestID = queryString.Get("request_id")
+
+	if actorID := queryString.Get("actor_id"); actorID != "" {
+		id, err := strconv.ParseInt(actorID, 10, 64)
+		if err != nil {
+			return q, err
+		}
+		q.ActorID = id
+	}
+
+	if targetID := queryString.Get("target_id"); targetID != "" {
+		id, err := strconv.ParseInt(targetID, 10, 64)
+		if err != nil {
+			return q, err
+		}
+		q.TargetID = id
+	}
+
+	if since := queryString.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return q, err
+		}
+		q.Since = t
+	}
+
+	if until := queryString.Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return q, err
+		}
+		q.Until = t
+	}
+
+	return q, nil
+}
+
+type FollowListQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Cursor string `json:"cursor" validate:"max=100"`
+}
+
+func (q FollowListQuery) Parse(r *http.Request) (FollowListQuery, error) {
+	queryString := r.URL.Query()
+
+	if limit := queryString.Get("limit"); limit != "" {
+		lmt, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = lmt
+	}
+
+	q.Cursor = queryString.Get("cursor")
+
+	return q, nil
+}
+
+type UserSearchQuery struct {
+	Q      string `json:"q" validate:"required,max=100"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset int    `json:"offset" validate:"gte=0"`
+}
+
+func (q UserSearchQuery) Parse(r *http.Request) (UserSearchQuery, error) {
+	queryString := r.URL.Query()
+
+	if limit := queryString.Get("limit"); limit != "" {
+		lmt, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = lmt
+	}
+
+	if offset := queryString.Get("offset"); offset != "" {
+		ofst, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = ofst
+	}
+
+	q.Q = strings.TrimSpace(queryString.Get("q"))
+
+	return q, nil
+}
+
+type AutocompleteQuery struct {
+	Q     string `json:"q" validate:"required,max=100"`
+	Limit int    `json:"limit" validate:"gte=1,lte=20"`
+}
+
+func (q AutocompleteQuery) Parse(r *http.Request) (AutocompleteQuery, error) {
+	queryString := r.URL.Query()
+
+	if limit := queryString.Get("limit"); limit != "" {
+		lmt, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = lmt
+	}
+
+	// pickers send what follows the @
+	q.Q = strings.TrimPrefix(strings.TrimSpace(queryString.Get("q")), "@")
+
+	return q, nil
+}
+
+type SuggestionQuery struct {
+	Limit int `json:"limit" validate:"gte=1,lte=50"`
+}
+
+func (q SuggestionQuery) Parse(r *http.Request) (SuggestionQuery, error) {
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		lmt, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = lmt
+	}
+
+	return q, nil
+}
+
+type DraftListQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=50"`
+	Offset int `json:"offset" validate:"gte=0"`
+}
+
+func (q DraftListQuery) Parse(r *http.Request) (DraftListQuery, error) {
+	queryString := r.URL.Query()
+
+	if limit := queryString.Get("limit"); limit != "" {
+		lmt, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = lmt
+	}
+
+	if offset := queryString.Get("offset"); offset != "" {
+		ofst, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = ofst
+	}
+
+	return q, nil
+}
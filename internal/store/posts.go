@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -17,10 +18,21 @@ type Post struct {
 	CreatedAt string     `json:"created_at"`
 	UpdatedAt string     `json:"updated_at"`
 	Version   int        `json:"version"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
 	Comments  []*Comment `json:"comments"`
 	User      User       `json:"user"`
 }
 
+// Post statuses. Only published posts are shown to anyone but their author.
+// PublishAt is when a scheduled post goes live, or when a published one did.
+// Drafts have none.
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+)
+
 type PostWithMetaData struct {
 	Post
 	CommentCount int `json:"comment_count"`
@@ -30,23 +42,42 @@ type PostStore struct {
 	db *sql.DB
 }
 
+// GetUserFeed returns the published posts of userID and of the users they
+// follow, leaving out users who are muted, blocked or blocking, deactivated or
+// suspended.
 func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, pfq PaginatedFeedQuery) ([]*PostWithMetaData, error) {
 	query := `
 		SELECT
-			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, u.username
-			COUNT(c.id) AS comment_count
+			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, u.username,
+			COUNT(DISTINCT c.id) AS comment_count
 		FROM posts p
-		LEFT JOIN comments c ON c.post_id = p.id
-		LEFT JOIN users u ON p.user_id = u.id
-		JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
+		LEFT JOIN comments c ON c.post_id = p.id AND c.hidden_at IS NULL AND EXISTS (
+			SELECT 1 FROM users cu
+			WHERE cu.id = c.user_id AND cu.is_active = true
+				AND (cu.suspended_until IS NULL OR cu.suspended_until <= NOW())
+		)
+		JOIN users u ON p.user_id = u.id
 		WHERE 
-			f.user_id = $1
+			(p.user_id = $1 OR EXISTS (
+				SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1
+			))
+			AND
+			p.status = 'published'
+			AND
+			u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())
 			AND
 			(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%' )
 			AND
 			(p.tags @> $5 OR $5 = '{}')
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.user_id = $1 AND b.blocked_id = p.user_id) OR (b.user_id = p.user_id AND b.blocked_id = $1)
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id
+			)
 		GROUP BY p.id, u.username
-		ORDER BY p.created_at` + pfq.Sort + `
+		ORDER BY p.publish_at ` + pfq.Sort + `
 		LIMIT $2
 		OFFSET $3
 	`
@@ -85,8 +116,8 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, pfq Paginated
 
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
 	query := `
-		INSERT INTO posts (content, title, user_id, tags)
-		VALUES($1, $2, $3, $4)
+		INSERT INTO posts (content, title, user_id, tags, status, publish_at)
+		VALUES($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
@@ -99,6 +130,8 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 		post.Title,
 		post.UserID,
 		pq.Array(post.Tags),
+		post.Status,
+		post.PublishAt,
 	).Scan(
 		&post.ID,
 		&post.CreatedAt,
@@ -114,7 +147,7 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 func (s *PostStore) GetByID(ctx context.Context, postID int64) (*Post, error) {
 
 	query := `
-		SELECT id, user_id, title, content, created_at, updated_at, tags, version
+		SELECT id, user_id, title, content, created_at, updated_at, tags, version, status, publish_at
 		FROM posts
 		WHERE id = $1
 	`
@@ -131,6 +164,8 @@ func (s *PostStore) GetByID(ctx context.Context, postID int64) (*Post, error) {
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
 		&post.Version,
+		&post.Status,
+		&post.PublishAt,
 	)
 	if err != nil {
 		switch {
@@ -147,7 +182,7 @@ func (s *PostStore) GetByID(ctx context.Context, postID int64) (*Post, error) {
 func (s *PostStore) Update(ctx context.Context, post *Post) error {
 	query := `
 		UPDATE posts
-		SET title = $1, content = $2, updated_at = NOW(), version = version + 1
+		SET title = $1, content = $2, status = $5, publish_at = $6, updated_at = NOW(), version = version + 1
 		WHERE id = $3 AND version = $4
 		RETURNING version
 	`
@@ -160,6 +195,8 @@ func (s *PostStore) Update(ctx context.Context, post *Post) error {
 		post.Content,
 		post.ID,
 		post.Version,
+		post.Status,
+		post.PublishAt,
 	).Scan(&post.Version)
 	if err != nil {
 		switch {
@@ -197,3 +234,84 @@ func (s *PostStore) Delete(ctx context.Context, postID int64) error {
 
 	return nil
 }
+
+// GetDrafts lists the drafts and scheduled posts of userID, most recently
+// edited first.
+func (s *PostStore) GetDrafts(ctx context.Context, userID int64, q DraftListQuery) ([]*Post, error) {
+	query := `
+		SELECT id, user_id, title, content, created_at, updated_at, tags, version, status, publish_at
+		FROM posts
+		WHERE user_id = $1 AND status <> 'published'
+		ORDER BY updated_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*Post{}
+	for rows.Next() {
+		post := &Post{}
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Title,
+			&post.Content,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			pq.Array(&post.Tags),
+			&post.Version,
+			&post.Status,
+			&post.PublishAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+// PublishDue publishes up to limit scheduled posts whose time has come and
+// returns their IDs. Rows are locked with SKIP LOCKED, so concurrent instances
+// never publish the same post twice. The version is bumped so an edit based on
+// the scheduled post cannot turn it back into a draft.
+func (s *PostStore) PublishDue(ctx context.Context, limit int) ([]int64, error) {
+	query := `
+		UPDATE posts
+		SET status = 'published', version = version + 1
+		WHERE id IN (
+			SELECT id FROM posts
+			WHERE status = 'scheduled' AND publish_at <= NOW()
+			ORDER BY publish_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type RefreshToken struct {
+	ID        int64        `json:"id"`
+	SessionID string       `json:"session_id"`
+	UserID    int64        `json:"user_id"`
+	Expiry    time.Time    `json:"expiry"`
+	RotatedAt sql.NullTime `json:"-"`
+	RevokedAt sql.NullTime `json:"-"`
+	CreatedAt string       `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+// Rotate exchanges a refresh token for a new one in the same session. Presenting
+// a token that was already rotated revokes the whole session and returns
+// ErrTokenReused, since only a stolen copy can be replayed that way.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token, newToken string, next *RefreshToken) error {
+	reused := false
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := s.getByToken(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt.Valid || time.Now().After(current.Expiry) {
+			return ErrNotFound
+		}
+
+		if current.RotatedAt.Valid {
+			reused = true
+			return revokeSessions(ctx, tx, `id = $1`, current.SessionID)
+		}
+
+		if err := s.markRotated(ctx, tx, current.ID); err != nil {
+			return err
+		}
+
+		next.SessionID = current.SessionID
+		next.UserID = current.UserID
+
+		return createRefreshToken(ctx, tx, newToken, next)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return ErrTokenReused
+	}
+
+	return nil
+}
+
+func (s *RefreshTokenStore) getByToken(ctx context.Context, tx *sql.Tx, token string) (*RefreshToken, error) {
+	query := `
+		SELECT id, session_id, user_id, expiry, rotated_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token = $1
+		FOR UPDATE
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rt := &RefreshToken{}
+	err := tx.QueryRowContext(ctx, query, hashToken(token)).Scan(
+		&rt.ID,
+		&rt.SessionID,
+		&rt.UserID,
+		&rt.Expiry,
+		&rt.RotatedAt,
+		&rt.RevokedAt,
+		&rt.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return rt, nil
+}
+
+func (s *RefreshTokenStore) markRotated(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET rotated_at = NOW()
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func createRefreshToken(ctx context.Context, tx *sql.Tx, token string, rt *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (token, session_id, user_id, expiry)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		hashToken(token),
+		rt.SessionID,
+		rt.UserID,
+		rt.Expiry,
+	).Scan(
+		&rt.ID,
+		&rt.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -4,12 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+
+	"github.com/lib/pq"
 )
 
+var ErrUnknownPermission = errors.New("unknown permission")
+
 type Role struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Level       int      `json:"level"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+type Permission struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
-	Level       int    `json:"level"`
 	Description string `json:"description"`
 }
 
@@ -47,3 +58,201 @@ func (r *RoleStore) GetByName(ctx context.Context, roleName string) (*Role, erro
 
 	return role, nil
 }
+
+// HasPermission reports whether the role was granted the named permission.
+func (r *RoleStore) HasPermission(ctx context.Context, roleID int64, permission string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM role_permissions rp
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE rp.role_id = $1 AND p.name = $2
+		)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var granted bool
+	err := r.db.QueryRowContext(ctx, query, roleID, permission).Scan(&granted)
+	if err != nil {
+		return false, err
+	}
+
+	return granted, nil
+}
+
+func (r *RoleStore) GetAll(ctx context.Context) ([]*Role, error) {
+	query := `
+		SELECT r.id, r.name, r.level, COALESCE(r.description, ''),
+			ARRAY(
+				SELECT p.name
+				FROM role_permissions rp
+				JOIN permissions p ON p.id = rp.permission_id
+				WHERE rp.role_id = r.id
+				ORDER BY p.name
+			)
+		FROM roles r
+		ORDER BY r.level, r.name
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*Role{}
+	for rows.Next() {
+		role := &Role{}
+		err := rows.Scan(
+			&role.ID,
+			&role.Name,
+			&role.Level,
+			&role.Description,
+			pq.Array(&role.Permissions),
+		)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (r *RoleStore) GetPermissions(ctx context.Context) ([]*Permission, error) {
+	query := `
+		SELECT id, name, description
+		FROM permissions
+		ORDER BY name
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []*Permission{}
+	for rows.Next() {
+		permission := &Permission{}
+		if err := rows.Scan(&permission.ID, &permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, nil
+}
+
+// Create adds a role with its permissions and records event along with it,
+// completed with the ID and permissions of the role.
+func (r *RoleStore) Create(ctx context.Context, role *Role, event *AuditEvent) error {
+	return withTX(r.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO roles (name, level, description)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, role.Name, role.Level, role.Description).Scan(&role.ID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrDataConflict
+			}
+			return err
+		}
+
+		if err := r.setPermissions(ctx, tx, role); err != nil {
+			return err
+		}
+
+		return createAuditEvent(ctx, tx, roleEvent(event, role))
+	})
+}
+
+// Update changes the level, description and permissions of a role and records
+// event along with it, completed like on Create.
+func (r *RoleStore) Update(ctx context.Context, role *Role, event *AuditEvent) error {
+	return withTX(r.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE roles
+			SET level = $1, description = $2
+			WHERE id = $3
+			RETURNING name
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, role.Level, role.Description, role.ID).Scan(&role.Name)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := r.setPermissions(ctx, tx, role); err != nil {
+			return err
+		}
+
+		return createAuditEvent(ctx, tx, roleEvent(event, role))
+	})
+}
+
+// setPermissions replaces the permissions of the role with role.Permissions.
+func (r *RoleStore) setPermissions(ctx context.Context, tx *sql.Tx, role *Role) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role_id = $1`, role.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, id FROM permissions WHERE name = ANY($2)
+	`
+	result, err := tx.ExecContext(ctx, query, role.ID, pq.Array(role.Permissions))
+	if err != nil {
+		return err
+	}
+
+	granted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	unique := map[string]bool{}
+	for _, name := range role.Permissions {
+		unique[name] = true
+	}
+
+	if granted != int64(len(unique)) {
+		return ErrUnknownPermission
+	}
+
+	return nil
+}
+
+// roleEvent completes event with the role it is about.
+func roleEvent(event *AuditEvent, role *Role) *AuditEvent {
+	event.TargetType = "role"
+	event.TargetID = role.ID
+	event.Metadata = map[string]any{
+		"name":        role.Name,
+		"level":       role.Level,
+		"description": role.Description,
+		"permissions": role.Permissions,
+	}
+	return event
+}
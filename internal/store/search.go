@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// UserSummary is a user as listed in search results.
+type UserSummary struct {
+	ID          int64             `json:"id"`
+	Username    string            `json:"username"`
+	DisplayName string            `json:"display_name"`
+	Avatar      map[string]string `json:"avatar,omitempty"`
+	// FollowedByViewer tells whether the user searching follows this user.
+	FollowedByViewer bool `json:"followed_by_viewer"`
+}
+
+// Search finds active users whose username or display name resembles q,
+// best matches first. Users blocking or blocked by viewerID are left out.
+func (s *UserStore) Search(ctx context.Context, viewerID int64, q UserSearchQuery) ([]*UserSummary, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar,
+			EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $2)
+		FROM users u
+		WHERE u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())
+			AND (u.username % $1 OR u.display_name % $1 OR u.username ILIKE '%' || $5 || '%' OR u.display_name ILIKE '%' || $5 || '%')
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.user_id = $2 AND b.blocked_id = u.id) OR (b.user_id = u.id AND b.blocked_id = $2)
+			)
+		ORDER BY GREATEST(similarity(u.username, $1), similarity(u.display_name, $1)) DESC, u.id
+		LIMIT $3 OFFSET $4
+	`
+
+	return s.listSummaries(ctx, query, q.Q, viewerID, q.Limit, q.Offset, escapeLike(q.Q))
+}
+
+// Autocomplete finds active users whose username starts with prefix, for
+// mention pickers. Users viewerID follows come first, then shorter names.
+func (s *UserStore) Autocomplete(ctx context.Context, viewerID int64, q AutocompleteQuery) ([]*UserSummary, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar, f.follower_id IS NOT NULL
+		FROM users u
+		LEFT JOIN followers f ON f.user_id = u.id AND f.follower_id = $2
+		WHERE lower(u.username) LIKE lower($1) || '%' AND u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.user_id = $2 AND b.blocked_id = u.id) OR (b.user_id = u.id AND b.blocked_id = $2)
+			)
+		ORDER BY f.follower_id IS NOT NULL DESC, length(u.username), u.username
+		LIMIT $3
+	`
+
+	return s.listSummaries(ctx, query, escapeLike(q.Q), viewerID, q.Limit)
+}
+
+func (s *UserStore) listSummaries(ctx context.Context, query string, args ...any) ([]*UserSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*UserSummary{}
+	for rows.Next() {
+		u := &UserSummary{}
+		var avatar []byte
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &avatar, &u.FollowedByViewer); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(avatar, &u.Avatar); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// escapeLike makes s match itself literally in a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
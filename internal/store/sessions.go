@@ -0,0 +1,240 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Session is a login on one device. Every access and refresh token belongs to
+// a session and stops working once it is revoked.
+type Session struct {
+	ID         string `json:"id"`
+	UserID     int64  `json:"user_id"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+// Create starts a session together with its first refresh token.
+func (s *SessionStore) Create(ctx context.Context, session *Session, token string, rt *RefreshToken) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO sessions (id, user_id, user_agent, ip)
+			VALUES ($1, $2, $3, $4)
+			RETURNING created_at, last_seen_at
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			session.ID,
+			session.UserID,
+			session.UserAgent,
+			session.IP,
+		).Scan(
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		rt.SessionID = session.ID
+		rt.UserID = session.UserID
+
+		return createRefreshToken(ctx, tx, token, rt)
+	})
+}
+
+func (s *SessionStore) GetByUserID(ctx context.Context, userID int64) ([]*Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session := &Session{}
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// Touch records activity on a session and reports whether it is still active.
+// Every authenticated request touches its session, so last_seen_at is only
+// written when it is more than a minute old.
+func (s *SessionStore) Touch(ctx context.Context, sessionID string) (bool, error) {
+	query := `
+		WITH active AS (
+			SELECT id, last_seen_at FROM sessions
+			WHERE id = $1 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE sessions
+			SET last_seen_at = NOW()
+			WHERE id IN (SELECT id FROM active WHERE last_seen_at < NOW() - interval '1 minute')
+		)
+		SELECT EXISTS (SELECT 1 FROM active)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var active bool
+	if err := s.db.QueryRowContext(ctx, query, sessionID).Scan(&active); err != nil {
+		return false, err
+	}
+
+	return active, nil
+}
+
+// StartedWithin reports whether the active session of userID began less than d
+// ago, meaning its owner signed in recently.
+func (s *SessionStore) StartedWithin(ctx context.Context, userID int64, sessionID string, d time.Duration) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM sessions
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+				AND created_at > NOW() - make_interval(secs => $3)
+		)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var recent bool
+	if err := s.db.QueryRowContext(ctx, query, sessionID, userID, d.Seconds()).Scan(&recent); err != nil {
+		return false, err
+	}
+
+	return recent, nil
+}
+
+// DeleteEnded removes the sessions that were revoked, or whose refresh tokens
+// all expired, more than retention ago, together with their refresh tokens.
+func (s *SessionStore) DeleteEnded(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `
+		DELETE FROM sessions s
+		WHERE s.revoked_at < NOW() - make_interval(secs => $1)
+			OR NOT EXISTS (
+				SELECT 1 FROM refresh_tokens rt
+				WHERE rt.session_id = s.id AND rt.expiry > NOW() - make_interval(secs => $1)
+			)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *SessionStore) Revoke(ctx context.Context, userID int64, sessionID string) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return revokeSessions(ctx, tx, `id = $1 AND user_id = $2`, sessionID, userID)
+	})
+}
+
+// RevokeOthers signs the user out everywhere except the given session.
+func (s *SessionStore) RevokeOthers(ctx context.Context, userID int64, keepSessionID string) error {
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return revokeSessions(ctx, tx, `user_id = $1 AND id <> $2`, userID, keepSessionID)
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+
+	return err
+}
+
+func (s *SessionStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return revokeSessions(ctx, tx, `user_id = $1`, userID)
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// revokeSessions revokes the active sessions matching where, and their refresh
+// tokens. It returns ErrNotFound when no session matched.
+func revokeSessions(ctx context.Context, tx *sql.Tx, where string, args ...any) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE revoked_at IS NULL AND ` + where + `
+		RETURNING id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return ErrNotFound
+	}
+
+	query = `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE session_id = ANY($1::uuid[]) AND revoked_at IS NULL
+	`
+	_, err = tx.ExecContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
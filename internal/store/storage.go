@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"time"
 )
@@ -13,6 +15,7 @@ var (
 	ErrDataConflict      = errors.New("resource data conflict")
 	ErrDuplicateEmail    = errors.New("dublicate email")
 	ErrDuplicateUsername = errors.New("dublicate username")
+	ErrTokenReused       = errors.New("refresh token reused")
 )
 
 var (
@@ -26,35 +29,163 @@ type Storage struct {
 		Update(context.Context, *Post) error
 		Delete(context.Context, int64) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]*PostWithMetaData, error)
+		GetDrafts(context.Context, int64, DraftListQuery) ([]*Post, error)
+		PublishDue(context.Context, int) ([]int64, error)
 	}
 	Users interface {
 		Create(context.Context, *sql.Tx, *User) error
 		GetUserByID(context.Context, int64) (*User, error)
+		Exists(context.Context, int64) (bool, error)
 		GetByEmail(context.Context, string) (*User, error)
+		GetDeactivatedByEmail(context.Context, string, time.Duration) (*User, error)
+		GetDeactivatedByID(context.Context, int64, time.Duration) (*User, error)
 		CreateAndInvite(context.Context, *User, string, time.Duration) error
-		Activate(context.Context, string) error
+		CreateWithIdentity(context.Context, *User, *Identity) error
+		Activate(context.Context, string) (*User, error)
 		Delete(context.Context, int64) error
+		CreatePasswordReset(context.Context, int64, string, time.Duration) error
+		ResetPassword(context.Context, string, string) (*User, error)
+		UpdatePassword(context.Context, *User) error
+		CreateEmailChange(context.Context, int64, string, string, time.Duration) error
+		ConfirmEmailChange(context.Context, int64, string) error
+		Reinvite(context.Context, string, string, time.Duration) (*User, error)
+		DeleteExpiredInvitations(context.Context) (int64, error)
+		PruneInactive(context.Context, time.Duration) (int64, error)
+		List(context.Context, UserListQuery) ([]*User, error)
+		SetRole(context.Context, int64, *Role, *AuditEvent) error
+		Deactivate(context.Context, int64, *AuditEvent) error
+		DeactivateSelf(context.Context, int64) error
+		Reactivate(context.Context, int64, time.Duration) error
+		Suspend(context.Context, int64, string, time.Time, *AuditEvent) error
+		Unsuspend(context.Context, int64, *AuditEvent) error
+		UpdateProfile(context.Context, *User) error
+		SetImage(context.Context, int64, string, map[string]string, []string) ([]string, error)
+		ScheduleDeletion(context.Context, int64, time.Time) error
+		CancelDeletion(context.Context, int64) error
+		GetDueDeletions(context.Context) ([]int64, error)
+		Purge(context.Context, int64) ([]string, error)
+		Search(context.Context, int64, UserSearchQuery) ([]*UserSummary, error)
+		Autocomplete(context.Context, int64, AutocompleteQuery) ([]*UserSummary, error)
 	}
 	Comments interface {
-		GetByPostID(context.Context, int64) ([]*Comment, error)
+		GetByPostID(context.Context, int64, int64) ([]*Comment, error)
 		Create(context.Context, *Comment) error
+		Hide(context.Context, *Comment) error
 	}
 	Followers interface {
 		Follow(context.Context, int64, int64) error
 		Unfollow(context.Context, int64, int64) error
+		GetFollowers(context.Context, int64, int64, FollowListQuery) ([]*FollowListEntry, string, error)
+		GetFollowing(context.Context, int64, int64, FollowListQuery) ([]*FollowListEntry, string, error)
+		GetCounts(context.Context, int64) (*FollowCounts, error)
+		IsFollowing(context.Context, int64, int64) (bool, error)
+		RequestFollow(context.Context, int64, int64) error
+		GetFollowRequests(context.Context, int64, FollowListQuery) ([]*FollowListEntry, string, error)
+		ApproveFollowRequest(context.Context, int64, int64) error
+		RejectFollowRequest(context.Context, int64, int64) error
+	}
+	Exports interface {
+		Create(context.Context, int64) (*DataExport, error)
+		ClaimNext(context.Context, time.Duration) (*DataExport, error)
+		Complete(context.Context, int64, string, string, time.Time) error
+		Fail(context.Context, int64) error
+		GetByUser(context.Context, int64) ([]*DataExport, error)
+		GetReady(context.Context, int64, int64) (*DataExport, error)
+		DeleteExpired(context.Context) ([]string, error)
+		GetData(context.Context, int64) (*ExportData, error)
+	}
+	Suggestions interface {
+		ClaimStale(context.Context, time.Duration, int) ([]int64, error)
+		Compute(context.Context, int64) error
+		Get(context.Context, int64, int) ([]*Suggestion, error)
+	}
+	Blocks interface {
+		Block(context.Context, int64, int64) error
+		Unblock(context.Context, int64, int64) error
+		IsBlocked(context.Context, int64, int64) (bool, error)
+		Mute(context.Context, int64, int64) error
+		Unmute(context.Context, int64, int64) error
+		GetBlocked(context.Context, int64, FollowListQuery) ([]*BlockedUser, string, error)
+		GetMuted(context.Context, int64, FollowListQuery) ([]*BlockedUser, string, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
+		HasPermission(context.Context, int64, string) (bool, error)
+		GetAll(context.Context) ([]*Role, error)
+		GetPermissions(context.Context) ([]*Permission, error)
+		Create(context.Context, *Role, *AuditEvent) error
+		Update(context.Context, *Role, *AuditEvent) error
+	}
+	RefreshTokens interface {
+		Rotate(context.Context, string, string, *RefreshToken) error
+	}
+	Sessions interface {
+		Create(context.Context, *Session, string, *RefreshToken) error
+		GetByUserID(context.Context, int64) ([]*Session, error)
+		Touch(context.Context, string) (bool, error)
+		StartedWithin(context.Context, int64, string, time.Duration) (bool, error)
+		Revoke(context.Context, int64, string) error
+		RevokeOthers(context.Context, int64, string) error
+		RevokeAllForUser(context.Context, int64) error
+		DeleteEnded(context.Context, time.Duration) (int64, error)
+	}
+	TwoFactor interface {
+		Get(context.Context, int64) (*TwoFactor, error)
+		Enroll(context.Context, int64, string) error
+		Confirm(context.Context, int64, int64, []string) error
+		UseStep(context.Context, int64, int64) error
+		UseRecoveryCode(context.Context, int64, string) error
+		CreateChallenge(context.Context, int64, string, time.Duration) error
+		AttemptChallenge(context.Context, string) (*User, error)
+		DeleteChallenge(context.Context, string) error
+	}
+	Identities interface {
+		GetUser(context.Context, string, string) (*User, error)
+		Link(context.Context, *Identity) error
+		CreateLoginState(context.Context, *LoginState, time.Duration) error
+		ConsumeLoginState(context.Context, string, string) (*LoginState, error)
+	}
+	APIKeys interface {
+		Create(context.Context, *APIKey, string) error
+		GetByUserID(context.Context, int64) ([]*APIKey, error)
+		Authenticate(context.Context, string) (*APIKey, error)
+		Revoke(context.Context, int64, int64) error
+	}
+	LoginFailures interface {
+		Get(context.Context, string, string) (*LoginFailure, error)
+		Record(context.Context, string, string, time.Duration, int, time.Duration) (*LoginFailure, error)
+		Reset(context.Context, string, string) error
+	}
+	AuditEvents interface {
+		Create(context.Context, []*AuditEvent) error
+		GetAll(context.Context, AuditEventQuery) ([]*AuditEvent, error)
+		DeleteBefore(context.Context, time.Time) (int64, error)
+	}
+	MagicLinks interface {
+		Create(context.Context, int64, string, string, time.Duration) error
+		Consume(context.Context, string, string) (int64, error)
+		RevokeAllForUser(context.Context, int64) error
 	}
 }
 
 func NewStore(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:         &PostStore{db},
+		Users:         &UserStore{db},
+		Comments:      &CommentStore{db},
+		Blocks:        &BlockStore{db},
+		Exports:       &ExportStore{db},
+		Suggestions:   &SuggestionStore{db},
+		Followers:     &FollowerStore{db},
+		Roles:         &RoleStore{db},
+		RefreshTokens: &RefreshTokenStore{db},
+		Sessions:      &SessionStore{db},
+		TwoFactor:     &TwoFactorStore{db},
+		Identities:    &IdentityStore{db},
+		APIKeys:       &APIKeyStore{db},
+		LoginFailures: &LoginFailureStore{db},
+		MagicLinks:    &MagicLinkStore{db},
+		AuditEvents:   &AuditEventStore{db},
 	}
 }
 
@@ -69,8 +200,14 @@ func withTX(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 
 	err = fn(tx)
 	if err != nil {
+		_ = tx.Rollback()
 		return err
 	}
 
 	return tx.Commit()
 }
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
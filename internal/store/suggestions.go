@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Reasons a user is suggested.
+const (
+	SuggestionMutualFollows  = "followed_by_people_you_follow"
+	SuggestionSharedTags     = "posts_about_your_tags"
+	SuggestionRecentlyActive = "recently_active"
+)
+
+// maxSuggestions is how many suggestions are kept per user.
+const maxSuggestions = 50
+
+// Suggestion is an account the user might want to follow.
+type Suggestion struct {
+	ID          int64             `json:"id"`
+	Username    string            `json:"username"`
+	DisplayName string            `json:"display_name"`
+	Avatar      map[string]string `json:"avatar,omitempty"`
+	// MutualCount is how many of the accounts the user follows follow this
+	// one.
+	MutualCount int      `json:"mutual_count"`
+	Reasons     []string `json:"reasons"`
+}
+
+type SuggestionStore struct {
+	db *sql.DB
+}
+
+// ClaimStale picks up to limit active users whose suggestions are missing or
+// older than maxAge and marks them as computed now. Rows are locked with SKIP
+// LOCKED, so concurrent instances never claim the same users.
+func (s *SuggestionStore) ClaimStale(ctx context.Context, maxAge time.Duration, limit int) ([]int64, error) {
+	query := `
+		UPDATE users
+		SET suggestions_computed_at = NOW()
+		WHERE id IN (
+			SELECT id FROM users
+			WHERE is_active = true AND (suspended_until IS NULL OR suspended_until <= NOW())
+				AND (suggestions_computed_at IS NULL
+					OR suggestions_computed_at < NOW() - make_interval(secs => $1))
+			ORDER BY suggestions_computed_at NULLS FIRST
+			FOR UPDATE SKIP LOCKED
+			LIMIT $2
+		)
+		RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, maxAge.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Compute replaces the suggestions of userID. Candidates are followed by the
+// accounts the user follows, post about the tags of the posts the user wrote
+// or commented on, or simply posted a lot lately; the last keeps new users
+// with an empty graph from getting nothing. Accounts the user follows, asked
+// to follow or has a block with are never suggested.
+func (s *SuggestionStore) Compute(ctx context.Context, userID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		query := `
+			DELETE FROM follow_suggestions WHERE user_id = $1
+		`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		query = `
+			WITH following AS (
+				SELECT user_id AS id FROM followers WHERE follower_id = $1
+			),
+			mutuals AS (
+				SELECT f.user_id AS id, COUNT(*) AS n
+				FROM followers f
+				JOIN following fo ON fo.id = f.follower_id
+				GROUP BY f.user_id
+			),
+			my_tags AS (
+				SELECT DISTINCT tag
+				FROM posts p, unnest(p.tags) AS tag
+				WHERE p.user_id = $1
+					OR p.id IN (SELECT post_id FROM comments WHERE user_id = $1)
+			),
+			shared_tags AS (
+				SELECT p.user_id AS id, COUNT(DISTINCT tag) AS n
+				FROM posts p, unnest(p.tags) AS tag
+				WHERE p.status = 'published' AND p.created_at > NOW() - interval '90 days'
+					AND tag IN (SELECT tag FROM my_tags)
+				GROUP BY p.user_id
+			),
+			activity AS (
+				SELECT user_id AS id, COUNT(*) AS n
+				FROM posts
+				WHERE status = 'published' AND created_at > NOW() - interval '14 days'
+				GROUP BY user_id
+			),
+			candidates AS (
+				SELECT id FROM mutuals
+				UNION
+				SELECT id FROM shared_tags
+				UNION
+				(SELECT id FROM activity ORDER BY n DESC LIMIT 50)
+			)
+			INSERT INTO follow_suggestions (user_id, suggested_id, score, mutual_count, reasons)
+			SELECT $1, c.id,
+				3 * COALESCE(m.n, 0) + 2 * COALESCE(t.n, 0) + 0.5 * LEAST(COALESCE(a.n, 0), 10) AS score,
+				COALESCE(m.n, 0),
+				array_remove(ARRAY[
+					CASE WHEN m.n > 0 THEN $2::text END,
+					CASE WHEN t.n > 0 THEN $3::text END,
+					CASE WHEN a.n > 0 THEN $4::text END
+				], NULL)
+			FROM candidates c
+			JOIN users u ON u.id = c.id AND u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())
+			LEFT JOIN mutuals m ON m.id = c.id
+			LEFT JOIN shared_tags t ON t.id = c.id
+			LEFT JOIN activity a ON a.id = c.id
+			WHERE c.id <> $1
+				AND c.id NOT IN (SELECT id FROM following)
+				AND NOT EXISTS (SELECT 1 FROM follow_requests r WHERE r.user_id = c.id AND r.requester_id = $1)
+				AND NOT EXISTS (
+					SELECT 1 FROM blocks b
+					WHERE (b.user_id = $1 AND b.blocked_id = c.id) OR (b.user_id = c.id AND b.blocked_id = $1)
+				)
+			ORDER BY score DESC
+			LIMIT $5
+		`
+		_, err := tx.ExecContext(ctx, query, userID,
+			SuggestionMutualFollows, SuggestionSharedTags, SuggestionRecentlyActive, maxSuggestions)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE users SET suggestions_computed_at = NOW() WHERE id = $1
+		`
+		_, err = tx.ExecContext(ctx, query, userID)
+		return err
+	})
+}
+
+// Get returns the best suggestions of userID. Accounts followed, blocked or
+// deactivated since the suggestions were computed are skipped. It returns
+// ErrNotFound when suggestions were never computed for the user.
+func (s *SuggestionStore) Get(ctx context.Context, userID int64, limit int) ([]*Suggestion, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var computedAt *time.Time
+	err := s.db.QueryRowContext(ctx, `SELECT suggestions_computed_at FROM users WHERE id = $1`, userID).Scan(&computedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	if computedAt == nil {
+		return nil, ErrNotFound
+	}
+
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar, s.mutual_count, s.reasons
+		FROM follow_suggestions s
+		JOIN users u ON u.id = s.suggested_id AND u.is_active = true AND (u.suspended_until IS NULL OR u.suspended_until <= NOW())
+		WHERE s.user_id = $1
+			AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $1)
+			AND NOT EXISTS (SELECT 1 FROM follow_requests r WHERE r.user_id = u.id AND r.requester_id = $1)
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.user_id = $1 AND b.blocked_id = u.id) OR (b.user_id = u.id AND b.blocked_id = $1)
+			)
+		ORDER BY s.score DESC, u.id
+		LIMIT $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*Suggestion{}
+	for rows.Next() {
+		sg := &Suggestion{}
+		var avatar []byte
+		if err := rows.Scan(&sg.ID, &sg.Username, &sg.DisplayName, &avatar, &sg.MutualCount, pq.Array(&sg.Reasons)); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(avatar, &sg.Avatar); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, sg)
+	}
+
+	return suggestions, rows.Err()
+}
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MaxTwoFactorAttempts is how many codes can be tried against a single login challenge.
+const MaxTwoFactorAttempts = 5
+
+type TwoFactor struct {
+	UserID       int64        `json:"user_id"`
+	Secret       string       `json:"-"`
+	LastUsedStep int64        `json:"-"`
+	ConfirmedAt  sql.NullTime `json:"-"`
+	CreatedAt    string       `json:"created_at"`
+}
+
+func (t *TwoFactor) Enabled() bool {
+	return t.ConfirmedAt.Valid
+}
+
+type TwoFactorStore struct {
+	db *sql.DB
+}
+
+func (s *TwoFactorStore) Get(ctx context.Context, userID int64) (*TwoFactor, error) {
+	query := `
+		SELECT user_id, secret, last_used_step, confirmed_at, created_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	tf := &TwoFactor{}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&tf.UserID,
+		&tf.Secret,
+		&tf.LastUsedStep,
+		&tf.ConfirmedAt,
+		&tf.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return tf, nil
+}
+
+// Enroll stores a new, unconfirmed secret for the user. A pending enrollment is
+// replaced, a confirmed one returns ErrDataConflict.
+func (s *TwoFactorStore) Enroll(ctx context.Context, userID int64, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrDataConflict
+	}
+
+	return nil
+}
+
+// Confirm enables two-factor authentication after the user proved they can
+// produce a code for step, and replaces their recovery codes.
+func (s *TwoFactorStore) Confirm(ctx context.Context, userID int64, step int64, recoveryCodes []string) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE user_totp
+			SET confirmed_at = NOW(), last_used_step = $2
+			WHERE user_id = $1 AND confirmed_at IS NULL AND last_used_step < $2
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		result, err := tx.ExecContext(ctx, query, userID, step)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return s.replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+// UseStep records that the code for step was used. Codes for the same or an
+// earlier step are rejected with ErrNotFound so they cannot be replayed.
+func (s *TwoFactorStore) UseStep(ctx context.Context, userID int64, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	query := `
+		UPDATE user_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, hashToken(code))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) CreateChallenge(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	query := `
+		INSERT INTO two_factor_challenges (token, user_id, expiry)
+		VALUES ($1, $2, $3)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, hashToken(token), userID, time.Now().Add(exp))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AttemptChallenge counts an attempt against a login challenge and returns the
+// user it belongs to, with only the ID, username and email set. Expired or
+// exhausted challenges return ErrNotFound.
+func (s *TwoFactorStore) AttemptChallenge(ctx context.Context, token string) (*User, error) {
+	query := `
+		UPDATE two_factor_challenges c
+		SET attempts = c.attempts + 1
+		FROM users u
+		WHERE u.id = c.user_id AND c.token = $1 AND c.expiry > $2 AND c.attempts < $3
+		RETURNING u.id, u.username, u.email
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, hashToken(token), time.Now(), MaxTwoFactorAttempts).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *TwoFactorStore) DeleteChallenge(ctx context.Context, token string) error {
+	query := `
+		DELETE FROM two_factor_challenges WHERE token = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_recovery_codes (user_id, code)
+		VALUES ($1, $2)
+	`
+	for _, code := range codes {
+		if _, err := tx.ExecContext(ctx, query, userID, hashToken(code)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -5,9 +5,13 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/ecetinerdem/gopherSocial/internal/auth"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,6 +24,63 @@ type User struct {
 	IsActive  bool     `json:"is_active"`
 	RoleID    int64    `json:"role_id"`
 	Role      Role     `json:"role"`
+	// Suspension is set while a moderator has the user suspended.
+	Suspension *Suspension `json:"suspension,omitempty"`
+	Profile
+}
+
+// Suspension keeps a user out until it ends.
+type Suspension struct {
+	Reason string    `json:"reason"`
+	Until  time.Time `json:"until"`
+}
+
+// Suspended reports whether the user is suspended right now. A cached user
+// stops being suspended when the suspension ends, without a reload.
+func (u *User) Suspended() bool {
+	return u.Suspension != nil && time.Now().Before(u.Suspension.Until)
+}
+
+func (u *User) setSuspension(until sql.NullTime, reason sql.NullString) {
+	u.Suspension = nil
+	if until.Valid && time.Now().Before(until.Time) {
+		u.Suspension = &Suspension{Reason: reason.String, Until: until.Time}
+	}
+}
+
+// Profile is what users tell about themselves. Empty fields are unset.
+type Profile struct {
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Website     string `json:"website"`
+	Location    string `json:"location"`
+	Pronouns    string `json:"pronouns"`
+	// Private accounts show their posts only to approved followers.
+	Private bool `json:"is_private"`
+	// Avatar and Banner map each size to the URL it is served from.
+	Avatar map[string]string `json:"avatar,omitempty"`
+	Banner map[string]string `json:"banner,omitempty"`
+}
+
+// Kinds of profile images.
+const (
+	UserImageAvatar = "avatar"
+	UserImageBanner = "banner"
+)
+
+var ErrUnknownImageKind = errors.New("unknown image kind")
+
+// passwordHasher hashes new passwords with Argon2id and still accepts the
+// bcrypt hashes stored before it. main replaces it with the configured one.
+var passwordHasher auth.PasswordHasher = auth.NewPasswordHashers(
+	auth.NewArgon2idHasher(64*1024, 3, 2),
+	auth.BcryptHasher{Cost: bcrypt.DefaultCost},
+)
+
+// SetPasswordHasher changes how passwords are hashed and verified. It must be
+// called before the store is used.
+func SetPasswordHasher(hasher auth.PasswordHasher) {
+	passwordHasher = hasher
 }
 
 type password struct {
@@ -28,14 +89,14 @@ type password struct {
 }
 
 func (p *password) Set(text string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(text), bcrypt.DefaultCost)
+	hash, err := passwordHasher.Hash(text)
 
 	if err != nil {
 		return err
 	}
 
 	p.text = &text
-	p.hash = hash
+	p.hash = []byte(hash)
 
 	return nil
 }
@@ -61,7 +122,7 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		role = "user"
 	}
 
-	err := s.db.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		query,
 		user.Username,
@@ -88,7 +149,9 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 
 func (s *UserStore) GetUserByID(ctx context.Context, userId int64) (*User, error) {
 	query := `
-		SELECT users.id, username, email, password, created_at, roles.*
+		SELECT users.id, username, email, password, created_at,
+			display_name, bio, website, location, pronouns, is_private, avatar, banner,
+			suspended_until, suspension_reason, roles.*
 		FROM users
 		JOIN roles on (users.role_id = roles.id)
 		WHERE users.id = $1 AND is_active = true
@@ -97,12 +160,25 @@ func (s *UserStore) GetUserByID(ctx context.Context, userId int64) (*User, error
 	defer cancel()
 
 	user := &User{}
+	var avatar, banner []byte
+	var suspendedUntil sql.NullTime
+	var suspensionReason sql.NullString
 	err := s.db.QueryRowContext(ctx, query, userId).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Website,
+		&user.Location,
+		&user.Pronouns,
+		&user.Private,
+		&avatar,
+		&banner,
+		&suspendedUntil,
+		&suspensionReason,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -117,26 +193,86 @@ func (s *UserStore) GetUserByID(ctx context.Context, userId int64) (*User, error
 			return nil, err
 		}
 	}
+	if err := json.Unmarshal(avatar, &user.Avatar); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(banner, &user.Banner); err != nil {
+		return nil, err
+	}
+	user.setSuspension(suspendedUntil, suspensionReason)
+
 	return user, nil
 
 }
 
+// Exists tells whether userID has an account, whether or not it is active.
+func (s *UserStore) Exists(ctx context.Context, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, email, password, created_at
+		SELECT id, username, email, password, created_at, is_active, suspended_until, suspension_reason
 		FROM users
 		WHERE email = $1 AND is_active = true
 	`
+
+	return s.getForLogin(ctx, query, email)
+}
+
+// GetDeactivatedByEmail returns the user who deactivated their own account
+// with email less than window ago, so that signing in can reactivate it.
+func (s *UserStore) GetDeactivatedByEmail(ctx context.Context, email string, window time.Duration) (*User, error) {
+	query := `
+		SELECT id, username, email, password, created_at, is_active, suspended_until, suspension_reason
+		FROM users
+		WHERE email = $1 AND is_active = false AND self_deactivated = true
+			AND deactivated_at > NOW() - make_interval(secs => $2)
+	`
+
+	return s.getForLogin(ctx, query, email, window.Seconds())
+}
+
+// GetDeactivatedByID is GetDeactivatedByEmail for sign-in methods that already
+// know the user, such as sign-in links and external identities.
+func (s *UserStore) GetDeactivatedByID(ctx context.Context, userID int64, window time.Duration) (*User, error) {
+	query := `
+		SELECT id, username, email, password, created_at, is_active, suspended_until, suspension_reason
+		FROM users
+		WHERE id = $1 AND is_active = false AND self_deactivated = true
+			AND deactivated_at > NOW() - make_interval(secs => $2)
+	`
+
+	return s.getForLogin(ctx, query, userID, window.Seconds())
+}
+
+func (s *UserStore) getForLogin(ctx context.Context, query string, args ...any) (*User, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
 	user := &User{}
-	err := s.db.QueryRowContext(ctx, query, email).Scan(
+	var suspendedUntil sql.NullTime
+	var suspensionReason sql.NullString
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
-		&user.Password,
+		&user.Password.hash,
 		&user.CreatedAt,
+		&user.IsActive,
+		&suspendedUntil,
+		&suspensionReason,
 	)
 
 	if err != nil {
@@ -147,8 +283,9 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 			return nil, err
 		}
 	}
-	return user, nil
+	user.setSuspension(suspendedUntil, suspensionReason)
 
+	return user, nil
 }
 
 func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExpiration time.Duration) error {
@@ -168,145 +305,1009 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token strin
 	})
 }
 
-func (s *UserStore) Activate(ctx context.Context, token string) error {
-
+// CreateWithIdentity creates an already active user for an external identity
+// whose provider has verified the email address.
+func (s *UserStore) CreateWithIdentity(ctx context.Context, user *User, identity *Identity) error {
 	return withTX(s.db, ctx, func(tx *sql.Tx) error {
-		user, err := s.getUserFromInvitation(ctx, tx, token)
+		if err := s.Create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		user.IsActive = true
+		if err := s.update(ctx, tx, user); err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return createIdentity(ctx, tx, identity)
+	})
+}
+
+// Activate activates the user the invitation token belongs to and returns it.
+func (s *UserStore) Activate(ctx context.Context, token string) (*User, error) {
+	var user *User
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		u, err := s.getUserFromInvitation(ctx, tx, token)
 
 		if err != nil {
 			return err
 		}
-		user.IsActive = true
+		u.IsActive = true
 
-		if err := s.update(ctx, tx, user); err != nil {
+		if err := s.update(ctx, tx, u); err != nil {
 			return err
 		}
 
-		if err := s.deleteUserInvitation(ctx, tx, user.ID); err != nil {
+		if err := s.deleteUserInvitation(ctx, tx, u.ID); err != nil {
 			return err
 		}
+
+		user = u
 		return nil
 	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
-func (s *UserStore) Delete(ctx context.Context, userID int64) error {
-	return withTX(s.db, ctx, func(tx *sql.Tx) error {
-		if err := s.delete(ctx, tx, userID); err != nil {
+// Reinvite replaces the invitation of a user that has not activated yet, so
+// only the newest activation link works.
+func (s *UserStore) Reinvite(ctx context.Context, email, token string, invitationExpiration time.Duration) (*User, error) {
+	var user *User
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		u, err := s.getInactiveByEmail(ctx, tx, email)
+		if err != nil {
 			return err
 		}
-		if err := s.deleteUserInvitation(ctx, tx, userID); err != nil {
+
+		if err := s.deleteUserInvitation(ctx, tx, u.ID); err != nil {
+			return err
+		}
+
+		if err := s.createUserInvitation(ctx, tx, token, invitationExpiration, u.ID); err != nil {
 			return err
 		}
+
+		user = u
 		return nil
 	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
-func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userId int64) error {
+// DeleteExpiredInvitations removes invitations whose activation link has expired.
+func (s *UserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
 	query := `
-		DELETE FROM users
-		WHERE id = $1
+		DELETE FROM user_invitation WHERE expiry < NOW()
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
-
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, userId)
+	result, err := s.db.ExecContext(ctx, query)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+
+	return result.RowsAffected()
 }
 
-func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token string, invitationExpiration time.Duration, userID int64) error {
+// PruneInactive deletes accounts that were never activated within gracePeriod
+// of signing up and have no usable activation link left. Their expired links
+// go with them through the foreign key.
+func (s *UserStore) PruneInactive(ctx context.Context, gracePeriod time.Duration) (int64, error) {
 	query := `
-		INSERT INTO user_invitations (
-			token, user_id, expiry
-		)
-		VALUES ($1, $2, $3)
+		DELETE FROM users u
+		WHERE u.is_active = false
+			AND u.deactivated_at IS NULL
+			AND u.created_at < NOW() - make_interval(secs => $1)
+			AND NOT EXISTS (
+				SELECT 1 FROM user_invitation ui
+				WHERE ui.user_id = u.id AND ui.expiry > NOW()
+			)
 	`
-
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
-
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(invitationExpiration))
-
+	result, err := s.db.ExecContext(ctx, query, gracePeriod.Seconds())
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return result.RowsAffected()
 }
 
-func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+// UpdatePassword stores the hash of a password that was just set on the user.
+func (s *UserStore) UpdatePassword(ctx context.Context, user *User) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		return s.updatePassword(ctx, tx, user)
+	})
+}
+
+// List returns users matching the admin filters, with their role.
+func (s *UserStore) List(ctx context.Context, q UserListQuery) ([]*User, error) {
 	query := `
-		SELECT u.id, u.username, u.email, u.created_at, u.is_active
-		FROM users u
-		JOIN user_invitation ui ON u.id = ui.user_id
-		WHERE ui.token = $1 AND ui.expiry > $2
+		SELECT users.id, username, email, created_at, is_active, roles.id, roles.name, roles.level, COALESCE(roles.description, '')
+		FROM users
+		JOIN roles ON (users.role_id = roles.id)
+		WHERE ($1 = '' OR username ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
+			AND ($2 = '' OR roles.name = $2)
+			AND ($3 = '' OR is_active::text = $3)
+		ORDER BY users.id
+		LIMIT $4 OFFSET $5
 	`
-	hash := sha256.Sum256([]byte(token))
-	hashToken := hex.EncodeToString(hash[:])
-
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	user := &User{}
-
-	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.CreatedAt,
-		&user.IsActive,
-	)
-
+	rows, err := s.db.QueryContext(ctx, query, q.Search, q.Role, q.Active, q.Limit, q.Offset)
 	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			return nil, ErrNotFound
-		default:
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user := &User{}
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.CreatedAt,
+			&user.IsActive,
+			&user.Role.ID,
+			&user.Role.Name,
+			&user.Role.Level,
+			&user.Role.Description,
+		)
+		if err != nil {
 			return nil, err
 		}
+		user.RoleID = user.Role.ID
+		users = append(users, user)
 	}
 
-	return user, nil
+	return users, nil
 }
 
-func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
-	query := `
-		UPDATE users
-		SET
-			username = $1, email = $2, is_active = $3
-		WHERE id = $4
-	`
+// UpdateProfile saves the username and profile of the user. A username that
+// is taken by someone else returns ErrDuplicateUsername. Making an account
+// public approves the follow requests it has pending.
+func (s *UserStore) UpdateProfile(ctx context.Context, user *User) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET username = $1, display_name = $2, bio = $3, website = $4, location = $5, pronouns = $6, is_private = $7
+			WHERE id = $8 AND is_active = true
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		result, err := tx.ExecContext(
+			ctx,
+			query,
+			user.Username,
+			user.DisplayName,
+			user.Bio,
+			user.Website,
+			user.Location,
+			user.Pronouns,
+			user.Private,
+			user.ID,
+		)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+				return ErrDuplicateUsername
+			default:
+				return err
+			}
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if user.Private {
+			return nil
+		}
+
+		return approveAllFollowRequests(ctx, tx, user.ID)
+	})
+}
+
+// SetImage replaces the avatar or banner of the user with the image served
+// from urls and stored under keys. It returns the keys of the image it
+// replaced so the caller can remove them.
+func (s *UserStore) SetImage(ctx context.Context, userID int64, kind string, urls map[string]string, keys []string) ([]string, error) {
+	var column string
+	switch kind {
+	case UserImageAvatar:
+		column = "avatar"
+	case UserImageBanner:
+		column = "banner"
+	default:
+		return nil, ErrUnknownImageKind
+	}
+
+	if urls == nil {
+		urls = map[string]string{}
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+
+	encoded, err := json.Marshal(urls)
+	if err != nil {
+		return nil, err
+	}
 
+	query := fmt.Sprintf(`
+		UPDATE users u
+		SET %[1]s = $1, %[1]s_keys = $2
+		FROM (SELECT id, %[1]s_keys FROM users WHERE id = $3 FOR UPDATE) old
+		WHERE u.id = old.id AND u.is_active = true
+		RETURNING old.%[1]s_keys
+	`, column)
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, user.Username, user.Email, user.IsActive, user.ID)
+	var old []string
+	err = s.db.QueryRowContext(ctx, query, string(encoded), pq.Array(keys), userID).Scan(pq.Array(&old))
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
 	}
 
-	return nil
+	return old, nil
 }
 
-func (s *UserStore) deleteUserInvitation(ctx context.Context, tx *sql.Tx, userID int64) error {
+// SetRole gives the user role and records event along with it.
+func (s *UserStore) SetRole(ctx context.Context, userID int64, role *Role, event *AuditEvent) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET role_id = $1
+			WHERE id = $2
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		result, err := tx.ExecContext(ctx, query, role.ID, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return createAuditEvent(ctx, tx, event)
+	})
+}
+
+// Deactivate locks an active user out, ends all their sessions and records
+// event along with it. A user who deactivated their own account can no longer
+// reactivate it afterwards.
+func (s *UserStore) Deactivate(ctx context.Context, userID int64, event *AuditEvent) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET is_active = false, deactivated_at = NOW(), self_deactivated = false
+			WHERE id = $1 AND (is_active = true OR self_deactivated = true)
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		result, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if err := revokeSessions(ctx, tx, `user_id = $1`, userID); err != nil && err != ErrNotFound {
+			return err
+		}
+
+		return createAuditEvent(ctx, tx, event)
+	})
+}
+
+// DeactivateSelf hides the account of userID until its owner signs in again
+// and ends all their sessions.
+func (s *UserStore) DeactivateSelf(ctx context.Context, userID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET is_active = false, deactivated_at = NOW(), self_deactivated = true
+			WHERE id = $1 AND is_active = true
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		result, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if err := revokeSessions(ctx, tx, `user_id = $1`, userID); err != nil && err != ErrNotFound {
+			return err
+		}
+		return nil
+	})
+}
+
+// Reactivate brings back the account of a user who deactivated it themselves
+// less than window ago. It returns ErrNotFound when the account is active, was
+// deactivated by an admin or has been deactivated for too long.
+func (s *UserStore) Reactivate(ctx context.Context, userID int64, window time.Duration) error {
 	query := `
-		DELETE FROM user_invitation WHERE user_id = $1
+		UPDATE users
+		SET is_active = true, deactivated_at = NULL, self_deactivated = false
+		WHERE id = $1 AND is_active = false AND self_deactivated = true
+			AND deactivated_at > NOW() - make_interval(secs => $2)
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, userID)
+	result, err := s.db.ExecContext(ctx, query, userID, window.Seconds())
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rows == 0 {
+		return ErrNotFound
+	}
 
 	return nil
 }
 
-func (p *password) Compare(text string) error {
-	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+// Suspend keeps userID out until the given time, ends all their sessions and
+// records event along with it. Suspending a suspended user replaces the reason
+// and end.
+func (s *UserStore) Suspend(ctx context.Context, userID int64, reason string, until time.Time, event *AuditEvent) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET suspended_until = $2, suspension_reason = $3
+			WHERE id = $1 AND is_active = true
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		result, err := tx.ExecContext(ctx, query, userID, until, reason)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if err := revokeSessions(ctx, tx, `user_id = $1`, userID); err != nil && err != ErrNotFound {
+			return err
+		}
+
+		return createAuditEvent(ctx, tx, event)
+	})
+}
+
+// Unsuspend lifts the suspension of userID early and records event along with
+// it. It returns ErrNotFound when the user is not suspended.
+func (s *UserStore) Unsuspend(ctx context.Context, userID int64, event *AuditEvent) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE users
+			SET suspended_until = NULL, suspension_reason = NULL
+			WHERE id = $1 AND suspended_until > NOW()
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		result, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return createAuditEvent(ctx, tx, event)
+	})
+}
+
+func (s *UserStore) Delete(ctx context.Context, userID int64) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.delete(ctx, tx, userID); err != nil {
+			return err
+		}
+		if err := s.deleteUserInvitation(ctx, tx, userID); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// ScheduleDeletion marks the account of userID to be purged at the given
+// time. Until then the user can still sign in and cancel.
+func (s *UserStore) ScheduleDeletion(ctx context.Context, userID int64, at time.Time) error {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = $2
+		WHERE id = $1 AND is_active = true
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, at)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CancelDeletion keeps the account of userID. It returns ErrNotFound when no
+// deletion was scheduled.
+func (s *UserStore) CancelDeletion(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = NULL
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetDueDeletions returns the users whose grace period is over.
+func (s *UserStore) GetDueDeletions(ctx context.Context) ([]int64, error) {
+	query := `
+		SELECT id FROM users
+		WHERE deletion_scheduled_at <= NOW()
+		ORDER BY deletion_scheduled_at
+		LIMIT 100
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Purge deletes a user whose grace period is over, together with their
+// posts, comments, follows and everything else hanging off the account. It
+// returns the keys of the blobs the user owned so the caller can remove them,
+// or ErrNotFound when the deletion was cancelled in the meantime.
+func (s *UserStore) Purge(ctx context.Context, userID int64) ([]string, error) {
+	var keys []string
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT avatar_keys || banner_keys || ARRAY(
+				SELECT blob_key FROM data_exports WHERE user_id = users.id AND blob_key IS NOT NULL
+			)
+			FROM users
+			WHERE id = $1 AND deletion_scheduled_at <= NOW()
+			FOR UPDATE
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(pq.Array(&keys)); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		// posts, comments, follows and the rest cascade from users
+		if err := s.deleteUserInvitation(ctx, tx, userID); err != nil {
+			return err
+		}
+		return s.delete(ctx, tx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		// only the most recently requested link stays usable
+		if err := s.deletePasswordResets(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		return s.createPasswordReset(ctx, tx, token, exp, userID)
+	})
+}
+
+func (s *UserStore) ResetPassword(ctx context.Context, token, newPassword string) (*User, error) {
+	var user *User
+
+	err := withTX(s.db, ctx, func(tx *sql.Tx) error {
+		u, err := s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if err := u.Password.Set(newPassword); err != nil {
+			return err
+		}
+
+		if err := s.updatePassword(ctx, tx, u); err != nil {
+			return err
+		}
+
+		if err := s.deletePasswordResets(ctx, tx, u.ID); err != nil {
+			return err
+		}
+
+		user = u
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateEmailChange stores a pending change to newEmail that takes effect once
+// the link sent to the new address is confirmed.
+func (s *UserStore) CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		taken, err := s.emailTaken(ctx, tx, newEmail)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateEmail
+		}
+
+		// only the most recently requested link stays usable
+		if err := s.deleteEmailChanges(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		return s.createEmailChange(ctx, tx, token, newEmail, exp, userID)
+	})
+}
+
+// ConfirmEmailChange swaps in the new email of a pending change made by the user.
+func (s *UserStore) ConfirmEmailChange(ctx context.Context, userID int64, token string) error {
+	return withTX(s.db, ctx, func(tx *sql.Tx) error {
+		user, newEmail, err := s.getUserFromEmailChange(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if user.ID != userID {
+			return ErrNotFound
+		}
+
+		if err := s.updateEmail(ctx, tx, user.ID, newEmail); err != nil {
+			return err
+		}
+
+		return s.deleteEmailChanges(ctx, tx, user.ID)
+	})
+}
+
+func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userId int64) error {
+	query := `
+		DELETE FROM users
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token string, invitationExpiration time.Duration, userID int64) error {
+	query := `
+		INSERT INTO user_invitation (
+			token, user_id, expiry
+		)
+		VALUES ($1, $2, $3)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(invitationExpiration))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active
+		FROM users u
+		JOIN user_invitation ui ON u.id = ui.user_id
+		WHERE ui.token = $1 AND ui.expiry > $2
+	`
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	user := &User{}
+
+	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `
+		UPDATE users
+		SET
+			username = $1, email = $2, is_active = $3
+		WHERE id = $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, user.Username, user.Email, user.IsActive, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) deleteUserInvitation(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		DELETE FROM user_invitation WHERE user_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) createPasswordReset(ctx context.Context, tx *sql.Tx, token string, exp time.Duration, userID int64) error {
+	query := `
+		INSERT INTO password_resets (token, user_id, expiry)
+		VALUES ($1, $2, $3)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, hashToken(token), userID, time.Now().Add(exp))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active
+		FROM users u
+		JOIN password_resets pr ON u.id = pr.user_id
+		WHERE pr.token = $1 AND pr.expiry > $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	user := &User{}
+
+	err := tx.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `
+		UPDATE users
+		SET password = $1
+		WHERE id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, user.Password.hash, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		DELETE FROM password_resets WHERE user_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) getInactiveByEmail(ctx context.Context, tx *sql.Tx, email string) (*User, error) {
+	query := `
+		SELECT id, username, email, created_at, is_active
+		FROM users
+		WHERE email = $1 AND is_active = false AND deactivated_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) emailTaken(ctx context.Context, tx *sql.Tx, email string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var taken bool
+	err := tx.QueryRowContext(ctx, query, email).Scan(&taken)
+	if err != nil {
+		return false, err
+	}
+
+	return taken, nil
+}
+
+func (s *UserStore) createEmailChange(ctx context.Context, tx *sql.Tx, token, newEmail string, exp time.Duration, userID int64) error {
+	query := `
+		INSERT INTO email_changes (token, user_id, new_email, expiry)
+		VALUES ($1, $2, $3, $4)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, hashToken(token), userID, newEmail, time.Now().Add(exp))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) getUserFromEmailChange(ctx context.Context, tx *sql.Tx, token string) (*User, string, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active, ec.new_email
+		FROM users u
+		JOIN email_changes ec ON u.id = ec.user_id
+		WHERE ec.token = $1 AND ec.expiry > $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	user := &User{}
+	var newEmail string
+
+	err := tx.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+		&newEmail,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, "", ErrNotFound
+		default:
+			return nil, "", err
+		}
+	}
+
+	return user, newEmail, nil
+}
+
+func (s *UserStore) updateEmail(ctx context.Context, tx *sql.Tx, userID int64, email string) error {
+	query := `
+		UPDATE users
+		SET email = $1
+		WHERE id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, email, userID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *UserStore) deleteEmailChanges(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		DELETE FROM email_changes WHERE user_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *password) Compare(text string) error {
+	return passwordHasher.Verify(text, string(p.hash))
+}
+
+// NeedsRehash reports whether the stored hash uses an older algorithm or
+// weaker parameters than new passwords get.
+func (p *password) NeedsRehash() bool {
+	return passwordHasher.NeedsRehash(string(p.hash))
 }